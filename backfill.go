@@ -0,0 +1,219 @@
+package txnotify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blockTagEarliest  = "earliest"
+	blockTagLatest    = "latest"
+	blockTagSafe      = "safe"
+	blockTagFinalized = "finalized"
+)
+
+// resolveBlock turns a block number (hex or decimal) or a block tag into a hex block number.
+func (watcher *Watcher) resolveBlock(block string) (string, error) {
+	switch block {
+	case blockTagEarliest:
+		return numToStr(0), nil
+
+	case blockTagLatest:
+		resp, err := watcher.rpcClient.GetCurrentBlockNumber()
+		if err != nil {
+			return "", fmt.Errorf("could not get latest block number: %w", err)
+		}
+
+		return resp.Result, nil
+
+	case blockTagSafe, blockTagFinalized:
+		resolved, err := watcher.getBlockByNumber(block)
+		if err != nil {
+			return "", fmt.Errorf("could not get %s block: %w", block, err)
+		}
+
+		return resolved.Number, nil
+	}
+
+	if strings.HasPrefix(block, "0x") {
+		num, err := strToHex(block)
+		if err != nil {
+			return "", err
+		}
+
+		return numToStr(num), nil
+	}
+
+	num, err := strconv.Atoi(block)
+	if err != nil {
+		return "", fmt.Errorf("invalid block number or tag '%s': %w", block, err)
+	}
+
+	return numToStr(num), nil
+}
+
+// initStartBlock positions the watcher on the configured start block, so that it is the first
+// block processed. Without a start block the watcher begins at the latest block.
+func (watcher *Watcher) initStartBlock() error {
+	if watcher.startBlock == "" || watcher.startBlock == blockTagLatest {
+		return nil
+	}
+
+	watcher.mu.Lock()
+	started := watcher.currentBlock != ""
+	watcher.mu.Unlock()
+
+	if started {
+		return nil
+	}
+
+	blockNum, err := watcher.resolveBlock(watcher.startBlock)
+	if err != nil {
+		return fmt.Errorf("could not resolve start block: %w", err)
+	}
+
+	watcher.logger.Info("starting from block", "startBlock", watcher.startBlock, "blockNum", blockNum)
+
+	watcher.mu.Lock()
+	watcher.currentBlock = blockNum
+	watcher.mu.Unlock()
+
+	return nil
+}
+
+// Backfill walks the historical block range [from, to], storing the blocks in the cache and
+// notifying their transactions. Both ends accept a block number or tag. If addresses are
+// given only those are notified, otherwise every subscribed address is.
+// Blocks are fetched in bursts of the configured batch size, separated by the batch delay.
+//
+// With Confirmations set, the blocks in the range that do not have them yet are queued like
+// those of Listen, and notified once they are confirmed, which requires Listen to be running.
+//
+// Backfill stops before the first block processed by Listen, so it can run alongside it: the
+// blocks from there on are notified by Listen. When Backfill runs first, Listen skips its start
+// block if it was backfilled, but not the blocks after it, so the range should end at the start
+// block of Listen (the latest block by default).
+func (watcher *Watcher) Backfill(ctx context.Context, from, to string, addresses ...string) error {
+	fromBlock, err := watcher.resolveBlock(from)
+	if err != nil {
+		return err
+	}
+
+	toBlock, err := watcher.resolveBlock(to)
+	if err != nil {
+		return err
+	}
+
+	start, err := strToHex(fromBlock)
+	if err != nil {
+		return fmt.Errorf("could not parse block number: %w", err)
+	}
+
+	end, err := strToHex(toBlock)
+	if err != nil {
+		return fmt.Errorf("could not parse block number: %w", err)
+	}
+
+	confirmed := end
+	if watcher.confirmations > 0 {
+		if confirmed, err = watcher.lastConfirmedBlock(); err != nil {
+			return err
+		}
+	}
+
+	subs := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		subs = append(subs, NormalizeAddress(addr))
+	}
+
+	if len(subs) == 0 {
+		subs = watcher.copyState().subs
+	}
+
+	if start > end {
+		return nil
+	}
+
+	watcher.logger.Info("backfilling blocks", "from", fromBlock, "to", toBlock)
+
+	for num := start; num <= end; num++ {
+		if num > start && watcher.batchSize > 0 && (num-start)%watcher.batchSize == 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("backfill interrupted: %w", ctx.Err())
+			case <-time.After(watcher.batchDelay):
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("backfill interrupted: %w", err)
+		}
+
+		done, err := watcher.backfillBlock(num, num <= confirmed, subs)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			watcher.logger.Info("leaving the rest of the range to the live loop", "from", numToStr(num), "to", toBlock)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// backfillBlock processes one block of Backfill, reporting true without processing it if Listen
+// has already processed it or a block before it. It holds blockMu, so that Listen does not start
+// at the block while it is being backfilled.
+func (watcher *Watcher) backfillBlock(num int, confirmed bool, subs []string) (bool, error) {
+	watcher.blockMu.Lock()
+	defer watcher.blockMu.Unlock()
+
+	watcher.mu.Lock()
+	liveStart := watcher.liveStart
+	watcher.mu.Unlock()
+
+	if liveStart != "" {
+		if startNum, err := strToHex(liveStart); err == nil && num >= startNum {
+			return true, nil
+		}
+	}
+
+	blockNum := numToStr(num)
+
+	if _, err := watcher.fetchBlockInfoIfNotExist(blockNum, subs); err != nil {
+		return false, fmt.Errorf("could not backfill block %s: %w", blockNum, err)
+	}
+
+	if err := watcher.cache.SetBlockProcessed(blockNum); err != nil {
+		return false, fmt.Errorf("could not set block %s as processed: %w", blockNum, err)
+	}
+
+	if confirmed {
+		watcher.notifyForBlock(EventMined, blockNum, subs, nil)
+		return false, nil
+	}
+
+	watcher.dispatchBlock(blockNum, subs, true)
+
+	return false, nil
+}
+
+// lastConfirmedBlock returns the number of the newest block with the configured confirmations.
+func (watcher *Watcher) lastConfirmedBlock() (int, error) {
+	latest, err := watcher.resolveBlock(blockTagLatest)
+	if err != nil {
+		return 0, err
+	}
+
+	num, err := strToHex(latest)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse block number: %w", err)
+	}
+
+	return num - watcher.confirmations, nil
+}
@@ -10,6 +10,7 @@ import (
 type Cache interface {
 	AddBlock(blockNum string, block ethereum.Block) error
 	GetBlock(blockNum string) (ethereum.Block, error)
+	RemoveBlock(blockNum string) error
 	GetBlockProcessed(blockNum string) (bool, error)
 	SetBlockProcessed(blockNum string) error
 	AddTx(tx ethereum.Transaction) error
@@ -66,6 +67,26 @@ func (cache *InMemoryCache) GetBlock(blockNum string) (ethereum.Block, error) {
 	return block, nil
 }
 
+// RemoveBlock evicts a block, its transactions and its processed state from the cache.
+func (cache *InMemoryCache) RemoveBlock(blockNum string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	block, exists := cache.blocks[blockNum]
+	if !exists {
+		return fmt.Errorf("block with number %s not found", blockNum)
+	}
+
+	for _, tx := range block.Transactions {
+		delete(cache.transactions, tx.Hash)
+	}
+
+	delete(cache.blocks, blockNum)
+	delete(cache.processedBlocks, blockNum)
+
+	return nil
+}
+
 func (cache *InMemoryCache) GetBlockProcessed(blockNum string) (bool, error) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
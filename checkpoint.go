@@ -0,0 +1,245 @@
+package txnotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+var ErrNoCheckpoint = errors.New("no checkpoint stored")
+
+// CheckpointStore persists the last block that was fully processed and notified, so a
+// Watcher can resume from it after a restart.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the stored block number, or ErrNoCheckpoint if there is none.
+	LoadCheckpoint() (string, error)
+	SaveCheckpoint(blockNum string) error
+}
+
+// NewFileCheckpointStore returns a CheckpointStore that keeps the checkpoint in a JSON file at path.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+type FileCheckpointStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+type checkpointFile struct {
+	BlockNumber string `json:"blockNumber"`
+}
+
+func (store *FileCheckpointStore) LoadCheckpoint() (string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoCheckpoint
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("could not read checkpoint: %w", err)
+	}
+
+	checkpoint := checkpointFile{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return "", fmt.Errorf("could not decode checkpoint: %w", err)
+	}
+
+	if checkpoint.BlockNumber == "" {
+		return "", ErrNoCheckpoint
+	}
+
+	return checkpoint.BlockNumber, nil
+}
+
+// SaveCheckpoint atomically replaces the checkpoint file by writing to a temporary file
+// in the same directory and renaming it.
+func (store *FileCheckpointStore) SaveCheckpoint(blockNum string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	data, err := json.Marshal(checkpointFile{BlockNumber: blockNum})
+	if err != nil {
+		return fmt.Errorf("could not encode checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not sync checkpoint: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("could not replace checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// resumeFromCheckpoint positions the watcher right after the stored checkpoint, so the blocks
+// produced while it was down are replayed through the normal processing pipeline.
+func (watcher *Watcher) resumeFromCheckpoint() error {
+	if watcher.checkpoints == nil {
+		return nil
+	}
+
+	blockNum, err := watcher.checkpoints.LoadCheckpoint()
+	if errors.Is(err, ErrNoCheckpoint) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not load checkpoint: %w", err)
+	}
+
+	num, err := strToHex(blockNum)
+	if err != nil {
+		return fmt.Errorf("could not parse checkpoint: %w", err)
+	}
+
+	watcher.logger.Info("resuming from checkpoint", "checkpoint", blockNum)
+
+	watcher.mu.Lock()
+	watcher.checkpoint = num
+	watcher.currentBlock = numToStr(num + 1)
+	watcher.mu.Unlock()
+
+	return nil
+}
+
+// blockDelivery counts the events of a notified block that have not been delivered or
+// dead-lettered yet. It holds one extra reference while the block's events are being queued.
+type blockDelivery struct {
+	blockNum    string
+	num         int
+	outstanding int
+}
+
+// beginDelivery registers a block whose mined events are about to be queued. The checkpoint
+// moves past it once endDelivery has been called and each of its events has been settled.
+func (watcher *Watcher) beginDelivery(blockNum string) *blockDelivery {
+	num, err := strToHex(blockNum)
+	if err != nil {
+		watcher.logger.Error("could not parse block number", "blockNum", blockNum, "error", err)
+		return nil
+	}
+
+	delivery := &blockDelivery{blockNum: blockNum, num: num, outstanding: 1}
+
+	watcher.deliveryMu.Lock()
+	watcher.deliveries = append(watcher.deliveries, delivery)
+	watcher.deliveryMu.Unlock()
+
+	return delivery
+}
+
+// endDelivery releases the reference taken by beginDelivery, once all of the block's events are queued.
+func (watcher *Watcher) endDelivery(delivery *blockDelivery) {
+	watcher.settleDelivery(delivery)
+}
+
+// trackDelivery adds a queued event to its block's outstanding count.
+func (watcher *Watcher) trackDelivery(delivery *blockDelivery) {
+	if delivery == nil {
+		return
+	}
+
+	watcher.deliveryMu.Lock()
+	delivery.outstanding++
+	watcher.deliveryMu.Unlock()
+}
+
+// settleDelivery records that one of a block's events was delivered or dead-lettered, and saves
+// the newest block that, along with every block before it, has no outstanding events left.
+// Blocks are settled out of order by the dispatcher's workers, so the checkpoint is saved under
+// deliveryMu to keep it from moving backwards.
+func (watcher *Watcher) settleDelivery(delivery *blockDelivery) {
+	if delivery == nil {
+		return
+	}
+
+	watcher.deliveryMu.Lock()
+	defer watcher.deliveryMu.Unlock()
+
+	delivery.outstanding--
+
+	var settled *blockDelivery
+
+	for len(watcher.deliveries) > 0 && watcher.deliveries[0].outstanding <= 0 {
+		settled = watcher.deliveries[0]
+		watcher.deliveries = watcher.deliveries[1:]
+	}
+
+	if settled != nil {
+		watcher.saveCheckpoint(settled.blockNum)
+	}
+}
+
+// saveCheckpoint records blockNum as the last notified block. Failures are logged, as the
+// worst outcome is replaying a few blocks after a restart.
+func (watcher *Watcher) saveCheckpoint(blockNum string) {
+	if watcher.checkpoints == nil {
+		return
+	}
+
+	num, err := strToHex(blockNum)
+	if err != nil {
+		watcher.logger.Error("could not parse checkpoint", "blockNum", blockNum, "error", err)
+		return
+	}
+
+	watcher.mu.Lock()
+	watcher.checkpoint = num
+	watcher.mu.Unlock()
+
+	if err := watcher.checkpoints.SaveCheckpoint(blockNum); err != nil {
+		watcher.logger.Error("could not save checkpoint", "blockNum", blockNum, "error", err)
+	}
+}
+
+// rewindCheckpoint moves the checkpoint back to blockNum if it is currently ahead of it. Blocks
+// past blockNum still being delivered are forgotten, as they are no longer canonical and must not
+// move the checkpoint forward when they settle.
+func (watcher *Watcher) rewindCheckpoint(blockNum string) {
+	num, err := strToHex(blockNum)
+	if err != nil {
+		return
+	}
+
+	watcher.deliveryMu.Lock()
+	defer watcher.deliveryMu.Unlock()
+
+	watcher.deliveries = slices.DeleteFunc(watcher.deliveries, func(delivery *blockDelivery) bool {
+		return delivery.num > num
+	})
+
+	watcher.mu.Lock()
+	ahead := watcher.checkpoint > num
+	watcher.mu.Unlock()
+
+	if ahead {
+		watcher.saveCheckpoint(blockNum)
+	}
+}
@@ -0,0 +1,157 @@
+package txnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	t.Run("it reports a missing checkpoint", func(tt *testing.T) {
+		store := NewFileCheckpointStore(filepath.Join(tt.TempDir(), "checkpoint.json"))
+
+		_, err := store.LoadCheckpoint()
+		if !errors.Is(err, ErrNoCheckpoint) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrNoCheckpoint)
+		}
+	})
+
+	t.Run("it loads the saved checkpoint", func(tt *testing.T) {
+		path := filepath.Join(tt.TempDir(), "checkpoint.json")
+
+		if err := NewFileCheckpointStore(path).SaveCheckpoint("0x154d535"); err != nil {
+			tt.Fatalf("could not save checkpoint: %v", err)
+		}
+
+		got, err := NewFileCheckpointStore(path).LoadCheckpoint()
+		if err != nil {
+			tt.Fatalf("could not load checkpoint: %v", err)
+		}
+
+		if got != "0x154d535" {
+			tt.Fatalf("got %s, want 0x154d535", got)
+		}
+	})
+}
+
+func TestWatcherResumesFromCheckpoint(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+
+	for n := 0x10; n <= 0x13; n++ {
+		tx := ethereum.Transaction{Hash: fmt.Sprintf("0xtx%x", n), From: alice}
+		chain.addBlock(numToStr(n), fmt.Sprintf("0xh%x", n), fmt.Sprintf("0xh%x", n-1), tx)
+	}
+
+	chain.setHead("0x13")
+
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+	if err := store.SaveCheckpoint("0x11"); err != nil {
+		t.Fatalf("could not save checkpoint: %v", err)
+	}
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.checkpoints = store
+
+	if err := watcher.resumeFromCheckpoint(); err != nil {
+		t.Fatalf("could not resume: %v", err)
+	}
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	notifier.waitFor(t, EventMined, alice, "0xtx12")
+	notifier.waitFor(t, EventMined, alice, "0xtx13")
+
+	if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: "0xtx11"}) {
+		t.Fatalf("checkpointed block should not be notified again")
+	}
+
+	waitForCheckpoint(t, store, "0x13")
+}
+
+func TestWatcherCheckpointsAfterDelivery(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.addBlock("0x11", "0xh11", "0xh10", ethereum.Transaction{Hash: "0xtx11", From: alice})
+	chain.addBlock("0x12", "0xh12", "0xh11")
+	chain.setHead("0x12")
+
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+	if err := store.SaveCheckpoint("0x10"); err != nil {
+		t.Fatalf("could not save checkpoint: %v", err)
+	}
+
+	notifier := &gatedNotifier{gate: make(chan struct{}), next: newRecordingNotifier()}
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.checkpoints = store
+
+	if err := watcher.resumeFromCheckpoint(); err != nil {
+		t.Fatalf("could not resume: %v", err)
+	}
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	// 0x12 has no events, but must not be checkpointed while the events of 0x11 are queued.
+	time.Sleep(20 * time.Millisecond)
+
+	if got, err := store.LoadCheckpoint(); err != nil || got != "0x10" {
+		t.Fatalf("got checkpoint %s (%v) before delivery, want 0x10", got, err)
+	}
+
+	close(notifier.gate)
+
+	notifier.next.waitFor(t, EventMined, alice, "0xtx11")
+	waitForCheckpoint(t, store, "0x12")
+}
+
+// gatedNotifier blocks every notification until its gate is closed.
+type gatedNotifier struct {
+	gate chan struct{}
+	next *recordingNotifier
+}
+
+func (g *gatedNotifier) Notify(event Event) error {
+	<-g.gate
+
+	return g.next.Notify(event)
+}
+
+// waitForCheckpoint waits for the store to hold want, as checkpoints are saved once events are delivered.
+func waitForCheckpoint(t *testing.T, store CheckpointStore, want string) {
+	t.Helper()
+
+	const (
+		timeout = time.Second
+		step    = 5 * time.Millisecond
+	)
+
+	var got string
+
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(step) {
+		got, _ = store.LoadCheckpoint()
+		if got == want {
+			return
+		}
+	}
+
+	t.Fatalf("got checkpoint %s, want %s", got, want)
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,8 +20,13 @@ type SubscriptionRequest struct {
 }
 
 type Notification struct {
-	Address string                 `json:"address"`
-	Txs     []ethereum.Transaction `json:"transactions"` //nolint:tagliatelle
+	Kind        string                 `json:"kind,omitempty"`
+	Address     string                 `json:"address"`
+	BlockNumber string                 `json:"blockNumber,omitempty"`
+	Txs         []ethereum.Transaction `json:"transactions"` //nolint:tagliatelle
+
+	TokenTransfers []ethereum.TokenTransfer `json:"tokenTransfers,omitempty"`
+	NFTTransfers   []ethereum.NFTTransfer   `json:"nftTransfers,omitempty"`
 }
 
 func main() {
@@ -62,9 +66,7 @@ func run(serverAddr string, addresses []string, timeoutDuration time.Duration) e
 	}
 	defer conn.Close()
 
-	var mu sync.Mutex
-
-	seenTxs := subscribe(conn, addresses)
+	subscribe(conn, addresses)
 
 	for {
 		select {
@@ -86,29 +88,83 @@ func run(serverAddr string, addresses []string, timeoutDuration time.Duration) e
 				continue
 			}
 
-			// this had to be added to drop duplicate server notifications.
-			mu.Lock()
-			if _, exists := seenTxs[notif.Address]; !exists {
-				seenTxs[notif.Address] = make(map[string]struct{})
+			for _, transfer := range notif.TokenTransfers {
+				fmt.Printf(
+					"%s) %s token transfer: %s -> %s, token=%s, amount=%s (tx: %s)\n",
+					notif.Address, notif.Kind, transfer.From, transfer.To,
+					transfer.Token, transfer.Amount, transfer.TransactionHash,
+				)
+			}
+
+			for _, transfer := range notif.NFTTransfers {
+				fmt.Printf(
+					"%s) %s %s transfer: %s -> %s, token=%s, id=%s, amount=%s (tx: %s)\n",
+					notif.Address, notif.Kind, transfer.Standard, transfer.From, transfer.To,
+					transfer.Token, transfer.TokenID, transfer.Amount, transfer.TransactionHash,
+				)
+			}
+
+			if notif.Kind == "pending" {
+				for _, tx := range notif.Txs {
+					fmt.Printf("%s) pending tx: %s\n", notif.Address, tx.Hash)
+				}
+
+				continue
+			}
+
+			if notif.Kind == "deployed" {
+				for _, tx := range notif.Txs {
+					if tx.ContractAddress != nil {
+						fmt.Printf("%s) deployed contract: %s (tx: %s)\n", notif.Address, *tx.ContractAddress, tx.Hash)
+					}
+				}
+
+				continue
+			}
+
+			if notif.Kind == "replaced" || notif.Kind == "dropped" {
+				for _, tx := range notif.Txs {
+					replacedBy := ""
+					if tx.ReplacedBy != nil {
+						replacedBy = " by " + *tx.ReplacedBy
+					}
+
+					fmt.Printf("%s) %s tx: %s%s\n", notif.Address, notif.Kind, tx.Hash, replacedBy)
+				}
+
+				continue
+			}
+
+			if notif.Kind == "unconfirmed" {
+				for _, tx := range notif.Txs {
+					fmt.Printf("%s) unconfirmed tx: %s in block %s\n", notif.Address, tx.Hash, notif.BlockNumber)
+				}
+
+				continue
+			}
+
+			if notif.Kind == "reverted" {
+				for _, tx := range notif.Txs {
+					fmt.Printf("%s) reverted tx: %s\n", notif.Address, tx.Hash)
+				}
+
+				continue
 			}
 
 			for _, tx := range notif.Txs {
-				if _, seen := seenTxs[notif.Address][tx.Hash]; !seen {
-					fmt.Printf("%s) got tx: %s\n", notif.Address, tx.Hash)
-					seenTxs[notif.Address][tx.Hash] = struct{}{}
+				status := ""
+				if tx.Receipt != nil && !tx.Receipt.Succeeded() {
+					status = " (failed)"
 				}
+
+				fmt.Printf("%s) got tx: %s in block %s%s\n", notif.Address, tx.Hash, notif.BlockNumber, status)
 			}
-			mu.Unlock()
 		}
 	}
 }
 
-func subscribe(conn *websocket.Conn, addresses []string) map[string]map[string]struct{} {
-	seenTxs := make(map[string]map[string]struct{})
-
+func subscribe(conn *websocket.Conn, addresses []string) {
 	for _, addr := range addresses {
-		seenTxs[addr] = make(map[string]struct{})
-
 		req := SubscriptionRequest{Address: addr}
 		buf := &bytes.Buffer{}
 
@@ -122,8 +178,6 @@ func subscribe(conn *websocket.Conn, addresses []string) map[string]map[string]s
 			continue
 		}
 	}
-
-	return seenTxs
 }
 
 func splitAddresses(addresses string) []string {
@@ -4,16 +4,27 @@ import (
 	"context"
 	"flag"
 	"log"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
 )
 
 func main() {
 	addr := ":8080"
 	rpcEndpoint := "https://eth.nodeconnect.org"
 	pollInterval := "5s"
+	checkpoint := ""
+	pending := false
+	deadLetters := ""
+	dropPolicy := ""
 
 	flag.StringVar(&addr, "addr", addr, "server address")
 	flag.StringVar(&rpcEndpoint, "rpc", rpcEndpoint, "RPC endpoint")
 	flag.StringVar(&pollInterval, "interval", pollInterval, "poll interval")
+	flag.StringVar(&checkpoint, "checkpoint", checkpoint, "file to persist the last processed block in")
+	flag.BoolVar(&pending, "pending", pending, "also notify transactions as they enter the mempool")
+	flag.StringVar(&deadLetters, "dead-letters", deadLetters, "file to store undeliverable events in")
+	flag.StringVar(&dropPolicy, "drop-policy", dropPolicy, "what to drop when the delivery queue is full (none, newest or oldest)")
 	flag.Parse()
 
 	if rpcEndpoint == "" || pollInterval == "" {
@@ -21,11 +32,26 @@ func main() {
 		return
 	}
 
-	server, err := NewServer(addr, rpcEndpoint, pollInterval)
+	interval, err := time.ParseDuration(pollInterval)
 	if err != nil {
-		log.Fatalf("server init error: %v", err)
+		log.Fatalf("could not parse duration: %v", err)
+	}
+
+	cfg := txnotify.Config{
+		PollInterval: interval,
+		WatchPending: pending,
+		DropPolicy:   txnotify.DropPolicy(dropPolicy),
+	}
+	if checkpoint != "" {
+		cfg.Checkpoints = txnotify.NewFileCheckpointStore(checkpoint)
 	}
 
+	if deadLetters != "" {
+		cfg.DeadLetters = txnotify.NewFileDeadLetterStore(deadLetters)
+	}
+
+	server := NewServer(addr, rpcEndpoint, cfg)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -3,37 +3,54 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
+	"maps"
+	"slices"
 
 	"github.com/gorilla/websocket"
 
-	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify"
 )
 
 type WebsocketNotifier struct {
 	server *Server
 }
 
-func (n *WebsocketNotifier) Notify(address string, txList []ethereum.Transaction) {
+func (n *WebsocketNotifier) Notify(event txnotify.Event) error {
+	return n.send(event)
+}
+
+// send writes the event to every connection subscribed to its address, narrowed down
+// to what each connection's subscriptions match. Write errors are only logged, as retrying
+// would send the event again to the connections that got it; the failed connection is
+// dropped once its reader notices.
+func (n *WebsocketNotifier) send(event txnotify.Event) error {
 	n.server.mu.Lock()
 	defer n.server.mu.Unlock()
 
-	notification := Notification{Address: address, Txs: txList}
-
-	buf := &bytes.Buffer{}
+	for conn, subs := range n.server.conns {
+		filtered, ok := filterFor(subs, event)
+		if !ok {
+			continue
+		}
 
-	if err := json.NewEncoder(buf).Encode(notification); err != nil {
-		log.Printf("marshal error: %v", err)
-		return
-	}
+		buf := &bytes.Buffer{}
 
-	data := buf.Bytes()
+		if err := json.NewEncoder(buf).Encode(filtered); err != nil {
+			return fmt.Errorf("could not encode event: %w", err)
+		}
 
-	for conn, subs := range n.server.conns {
-		if _, ok := subs[address]; ok {
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Printf("write error: %v", err)
-			}
+		if err := conn.WriteMessage(websocket.TextMessage, buf.Bytes()); err != nil {
+			log.Printf("write error: %v", err)
 		}
 	}
+
+	return nil
+}
+
+// filterFor narrows an event down to what a connection's subscriptions match, reporting false
+// if nothing is left for it.
+func filterFor(subs map[string]txnotify.Subscription, event txnotify.Event) (txnotify.Event, bool) {
+	return txnotify.FilterEvent(event, slices.Collect(maps.Values(subs)))
 }
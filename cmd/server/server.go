@@ -12,7 +12,6 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/aalbacetef/txnotify"
-	"github.com/aalbacetef/txnotify/ethereum"
 )
 
 const (
@@ -22,51 +21,51 @@ const (
 	// NOTE: this should probably be lower with clients sending keep alive messages.
 	defaultIdleTimeout       = 5 * time.Minute
 	defaultReadHeaderTimeout = 5 * time.Second
+
+	// maxMessageSize bounds a client's subscription request, leaving room for a filter
+	// expression of expr.MaxLength bytes alongside counterparty and selector lists.
+	maxMessageSize = 64 * 1024
+
+	// dedupTTL is how long a notified transaction is not sent again to clients.
+	dedupTTL = time.Hour
 )
 
 type Server struct {
-	mu           sync.Mutex
-	conns        map[*websocket.Conn]map[string]struct{}
-	watcher      *txnotify.Watcher
-	addr         string
-	rpcEndpoint  string
-	pollInterval time.Duration
-	upgrader     websocket.Upgrader
+	mu          sync.Mutex
+	conns       map[*websocket.Conn]map[string]txnotify.Subscription
+	watcher     *txnotify.Watcher
+	addr        string
+	rpcEndpoint string
+	cfg         txnotify.Config
+	upgrader    websocket.Upgrader
 }
 
+// SubscriptionRequest subscribes the connection to an address, optionally with the filters of
+// txnotify.Subscription (direction, minValue, counterparties, excludedCounterparties, selectors, expression).
 type SubscriptionRequest struct {
-	Address string `json:"address"`
+	txnotify.Subscription
 }
 
-type Notification struct {
-	Address string                 `json:"address"`
-	Txs     []ethereum.Transaction `json:"transactions"` //nolint:tagliatelle
-}
-
-func NewServer(addr, rpcEndpoint, pollInterval string) (*Server, error) {
-	interval, err := time.ParseDuration(pollInterval)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse duration: %w", err)
-	}
-
+func NewServer(addr, rpcEndpoint string, cfg txnotify.Config) *Server {
 	return &Server{
-		conns:        make(map[*websocket.Conn]map[string]struct{}),
-		addr:         addr,
-		rpcEndpoint:  rpcEndpoint,
-		pollInterval: interval,
+		conns:       make(map[*websocket.Conn]map[string]txnotify.Subscription),
+		addr:        addr,
+		rpcEndpoint: rpcEndpoint,
+		cfg:         cfg,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  defaultBufSize,
 			WriteBufferSize: defaultBufSize,
 			CheckOrigin:     func(_ *http.Request) bool { return true },
 		},
-	}, nil
+	}
 }
 
 func (s *Server) Start(ctx context.Context) error {
-	notifier := &WebsocketNotifier{server: s}
-	cfg := txnotify.Config{PollInterval: s.pollInterval}
+	// drop transactions notified again while the server runs, e.g. when an event is retried.
+	// Dedup only remembers them in memory, so they are not dropped across restarts.
+	notifier := txnotify.Chain(&WebsocketNotifier{server: s}, txnotify.Dedup(dedupTTL))
 
-	watcher, err := txnotify.NewWatcher(s.rpcEndpoint, cfg, notifier)
+	watcher, err := txnotify.NewWatcher(s.rpcEndpoint, s.cfg, notifier)
 	if err != nil {
 		return fmt.Errorf("NewWatcher: %w", err)
 	}
@@ -106,15 +105,13 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	conn.SetReadLimit(maxMessageSize)
+
 	s.mu.Lock()
-	s.conns[conn] = make(map[string]struct{})
+	s.conns[conn] = make(map[string]txnotify.Subscription)
 	s.mu.Unlock()
 
-	defer func() {
-		s.mu.Lock()
-		delete(s.conns, conn)
-		s.mu.Unlock()
-	}()
+	defer s.dropConn(conn)
 
 	for {
 		_, msg, err := conn.ReadMessage()
@@ -135,13 +132,48 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		if err := s.watcher.Subscribe(req.Address); err != nil {
-			log.Printf("subscribe error: %v", err)
-			continue
-		}
+		s.subscribe(conn, req.Subscription)
+	}
+}
 
-		s.mu.Lock()
-		s.conns[conn][req.Address] = struct{}{}
-		s.mu.Unlock()
+// subscribe adds sub to the connection's subscriptions. Each connection holds at most
+// one reference to a subscription in the watcher.
+func (s *Server) subscribe(conn *websocket.Conn, sub txnotify.Subscription) {
+	// compiled once here, as the connection's notifications are matched against it.
+	sub, err := sub.Compiled()
+	if err != nil {
+		log.Printf("subscribe error: %v", err)
+		return
 	}
+
+	key := sub.Key()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.conns[conn][key]; ok {
+		return
+	}
+
+	if err := s.watcher.Subscribe(sub); err != nil {
+		log.Printf("subscribe error: %v", err)
+		return
+	}
+
+	s.conns[conn][key] = sub
+}
+
+// dropConn forgets a closed connection and releases its subscriptions, so the watcher stops
+// watching addresses no connected client is interested in.
+func (s *Server) dropConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.conns[conn] {
+		if err := s.watcher.Unsubscribe(sub); err != nil {
+			log.Printf("unsubscribe error: %v", err)
+		}
+	}
+
+	delete(s.conns, conn)
 }
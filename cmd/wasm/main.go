@@ -92,7 +92,7 @@ func main() {
 			return RetErr
 		}
 
-		if err := watcher.Subscribe(state.address); err != nil {
+		if err := watcher.Subscribe(txnotify.Subscription{Address: state.address}); err != nil {
 			fmt.Println("could not subscribe: ", err)
 			return RetErr
 		}
@@ -117,10 +117,14 @@ func main() {
 
 type mockNotifier struct{}
 
-func (mockNotifier) Notify(address string, txList []ethereum.Transaction) {
+func (mockNotifier) Notify(event txnotify.Event) error {
+	if event.Kind != txnotify.EventMined {
+		return nil
+	}
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
-	for _, tx := range txList {
+	for _, tx := range event.Transactions {
 		jsArray, err := serialize[ethereum.Transaction](tx)
 		if err != nil {
 			logger.Error("could not serialize data", "error", err)
@@ -129,4 +133,6 @@ func (mockNotifier) Notify(address string, txList []ethereum.Transaction) {
 
 		js.Global().Get("WASM_listenNotification").Invoke(jsArray)
 	}
+
+	return nil
 }
@@ -9,35 +9,82 @@ import (
 	"time"
 
 	"github.com/aalbacetef/txnotify"
-	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/email"
+	"github.com/aalbacetef/txnotify/jsonl"
+	"github.com/aalbacetef/txnotify/webhook"
 )
 
 type mockNotifier struct{}
 
-func (mockNotifier) Notify(address string, txList []ethereum.Transaction) {
+func (mockNotifier) Notify(event txnotify.Event) error {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
-	for _, tx := range txList {
+	for _, tx := range event.Transactions {
 		logger.Info(
-			"notification: got tx",
-			"address", address,
+			"notification: got "+string(event.Kind)+" tx",
+			"address", event.Address,
 			"hash", tx.Hash,
+			"block", event.BlockNumber,
+			"sequence", event.Sequence,
+			"succeeded", tx.Receipt != nil && tx.Receipt.Succeeded(),
 		)
 	}
+
+	return nil
 }
 
 func main() {
 	address := ""
 	pollInterval := "5s"
 	rpcEndpoint := "https://eth.nodeconnect.org"
+	confirmations := 0
+	startBlock := ""
+	checkpoint := ""
+	pending := false
+	direction := ""
+	minValue := ""
+	expression := ""
+	track := ""
+	webhookURL := ""
+	webhookSecret := ""
+	smtpAddr := ""
+	smtpUser := ""
+	startTLS := false
+	emailFrom := ""
+	emailTo := ""
+	emailWindow := time.Duration(0)
+	out := ""
+	outMaxSize := int64(0)
+	outMaxAge := time.Duration(0)
+	outSync := false
 
 	flag.StringVar(&address, "address", address, "address to subscribe to")
 	flag.StringVar(&pollInterval, "interval", pollInterval, "poll interval")
 	flag.StringVar(&rpcEndpoint, "rpc", rpcEndpoint, "RPC endpoint")
+	flag.StringVar(&startBlock, "start", startBlock, "block number or tag to start from (default: latest)")
+	flag.StringVar(&checkpoint, "checkpoint", checkpoint, "file to persist the last processed block in")
+	flag.BoolVar(&pending, "pending", pending, "also notify transactions as they enter the mempool")
+	flag.StringVar(&direction, "direction", direction, "only notify incoming or outgoing transactions")
+	flag.StringVar(&minValue, "min-value", minValue, "only notify transactions transferring at least this many wei")
+	flag.StringVar(&expression, "filter", expression, "only notify transactions matching this expression")
+	flag.StringVar(&track, "tx", track, "transaction hash to follow until it is confirmed")
+	flag.StringVar(&webhookURL, "webhook", webhookURL, "URL to POST notifications to")
+	flag.StringVar(&webhookSecret, "webhook-secret", webhookSecret, "secret to sign webhook requests with")
+	flag.StringVar(&smtpAddr, "smtp", smtpAddr, "SMTP server (host:port) to email notifications through")
+	flag.StringVar(&smtpUser, "smtp-user", smtpUser, "SMTP username, the password is read from SMTP_PASSWORD")
+	flag.BoolVar(&startTLS, "smtp-starttls", startTLS, "upgrade the SMTP connection with STARTTLS")
+	flag.StringVar(&emailFrom, "email-from", emailFrom, "sender of the notification emails")
+	flag.StringVar(&emailTo, "email-to", emailTo, "recipient of the notification emails")
+	flag.DurationVar(&emailWindow, "email-window", emailWindow, "batch the notifications of this long into one email")
+	flag.StringVar(&out, "out", out, "file to append notifications to as JSON lines (- for stdout)")
+	flag.Int64Var(&outMaxSize, "out-max-size", outMaxSize, "rotate the -out file once it grows past this many bytes")
+	flag.DurationVar(&outMaxAge, "out-max-age", outMaxAge, "rotate the -out file once it is this old")
+	flag.BoolVar(&outSync, "out-sync", outSync, "fsync the -out file after every notification")
+	flag.IntVar(&confirmations, "confirmations", confirmations, "blocks to wait before notifying a transaction")
 
 	flag.Parse()
 
-	if address == "" || pollInterval == "" || rpcEndpoint == "" {
+	if (address == "" && track == "") || pollInterval == "" || rpcEndpoint == "" {
 		flag.Usage()
 		return
 	}
@@ -48,9 +95,94 @@ func main() {
 		return
 	}
 
-	cfg := txnotify.Config{PollInterval: interval}
+	cfg := txnotify.Config{
+		PollInterval:  interval,
+		StartBlock:    startBlock,
+		Confirmations: confirmations,
+		WatchPending:  pending,
+	}
+
+	if checkpoint != "" {
+		cfg.Checkpoints = txnotify.NewFileCheckpointStore(checkpoint)
+	}
+
+	// the events of a tracked transaction are addressed to its sender, which is not known yet,
+	// so with -tx the sinks get the events of every address.
+	sinkAddress := address
+	if track != "" {
+		sinkAddress = txnotify.AnyAddress
+	}
+
+	var routes []txnotify.Route
+
+	if webhookURL != "" {
+		hooks := webhook.NewNotifier(webhook.Options{})
+		defer hooks.Close()
+
+		if err := hooks.AddEndpoint(sinkAddress, webhook.Endpoint{URL: webhookURL, Secret: webhookSecret}); err != nil {
+			fmt.Println("webhook error: ", err)
+			return
+		}
+
+		routes = append(routes, txnotify.Route{Name: "webhook", Notifier: hooks})
+	}
+
+	if smtpAddr != "" {
+		mailer, err := email.NewNotifier(email.Options{
+			Addr:     smtpAddr,
+			From:     emailFrom,
+			Username: smtpUser,
+			Password: os.Getenv("SMTP_PASSWORD"),
+			StartTLS: startTLS,
+			Window:   emailWindow,
+		})
+		if err != nil {
+			fmt.Println("email error: ", err)
+			return
+		}
+
+		if err := mailer.AddRecipients(sinkAddress, emailTo); err != nil {
+			fmt.Println("email error: ", err)
+			return
+		}
+
+		defer mailer.Close()
+
+		routes = append(routes, txnotify.Route{Name: "email", Notifier: mailer})
+	}
 
-	watcher, err := txnotify.NewWatcher(rpcEndpoint, cfg, mockNotifier{})
+	if out != "" {
+		sink, err := jsonl.NewNotifier(jsonl.Options{
+			Path:    out,
+			MaxSize: outMaxSize,
+			MaxAge:  outMaxAge,
+			Sync:    outSync,
+		})
+		if err != nil {
+			fmt.Println("out error: ", err)
+			return
+		}
+
+		defer sink.Close()
+
+		routes = append(routes, txnotify.Route{Name: "out", Notifier: sink})
+	}
+
+	var notifier txnotify.Notifier = mockNotifier{}
+
+	if len(routes) > 0 {
+		multi, err := txnotify.NewMultiNotifier(routes...)
+		if err != nil {
+			fmt.Println("error: ", err)
+			return
+		}
+
+		defer multi.Close()
+
+		notifier = multi
+	}
+
+	watcher, err := txnotify.NewWatcher(rpcEndpoint, cfg, notifier)
 	if err != nil {
 		fmt.Println("error: ", err)
 		return
@@ -61,9 +193,25 @@ func main() {
 
 	defer watcher.Close()
 
-	if err := watcher.Subscribe(address); err != nil {
-		fmt.Println("subscribe error: ", err)
-		return
+	sub := txnotify.Subscription{
+		Address:    address,
+		Direction:  txnotify.Direction(direction),
+		MinValue:   minValue,
+		Expression: expression,
+	}
+
+	if address != "" {
+		if err := watcher.Subscribe(sub); err != nil {
+			fmt.Println("subscribe error: ", err)
+			return
+		}
+	}
+
+	if track != "" {
+		if err := watcher.TrackTx(track); err != nil {
+			fmt.Println("track error: ", err)
+			return
+		}
 	}
 
 	if err := watcher.Listen(ctx); err != nil {
@@ -0,0 +1,115 @@
+package txnotify
+
+// pendingBlock is a processed block whose notifications are held until it has enough confirmations.
+type pendingBlock struct {
+	blockNum string
+	num      int
+	subs     []string
+
+	// backfilled blocks are notified without moving the checkpoint, see Backfill.
+	backfilled bool
+}
+
+// dispatchBlock sends the notifications for a freshly processed block. Without a confirmation
+// depth the block is notified right away, otherwise it is queued until enough blocks are built on top.
+func (watcher *Watcher) dispatchBlock(blockNum string, subs []string, backfilled bool) {
+	pending := pendingBlock{blockNum: blockNum, subs: subs, backfilled: backfilled}
+
+	if watcher.confirmations <= 0 {
+		watcher.notifyConfirmed(pending)
+		return
+	}
+
+	num, err := strToHex(blockNum)
+	if err != nil {
+		watcher.logger.Error("could not parse block number", "blockNum", blockNum, "error", err)
+		return
+	}
+
+	if watcher.notifyUnconfirmed {
+		watcher.notifyForBlock(EventUnconfirmed, blockNum, subs, nil)
+	}
+
+	watcher.mu.Lock()
+	pending.num = num
+	watcher.pendingConfirmations = append(watcher.pendingConfirmations, pending)
+	watcher.mu.Unlock()
+
+	watcher.releaseConfirmed()
+}
+
+// releaseConfirmed notifies every pending block that is at least the configured number
+// of confirmations behind the latest block.
+func (watcher *Watcher) releaseConfirmed() {
+	watcher.mu.Lock()
+
+	latest, err := strToHex(watcher.latestBlock)
+	if err != nil || len(watcher.pendingConfirmations) == 0 {
+		watcher.mu.Unlock()
+		return
+	}
+
+	var released []pendingBlock
+
+	remaining := watcher.pendingConfirmations[:0]
+
+	for _, pending := range watcher.pendingConfirmations {
+		if latest-pending.num >= watcher.confirmations {
+			released = append(released, pending)
+			continue
+		}
+
+		remaining = append(remaining, pending)
+	}
+
+	watcher.pendingConfirmations = remaining
+	watcher.mu.Unlock()
+
+	for _, pending := range released {
+		watcher.logger.Info(
+			"block confirmed",
+			"blockNum", pending.blockNum,
+			"confirmations", latest-pending.num,
+		)
+
+		watcher.notifyConfirmed(pending)
+	}
+}
+
+// notifyConfirmed sends the mined notifications of a block that needs no more confirmations.
+func (watcher *Watcher) notifyConfirmed(pending pendingBlock) {
+	if pending.backfilled {
+		watcher.notifyForBlock(EventMined, pending.blockNum, pending.subs, nil)
+		return
+	}
+
+	watcher.notifyMined(pending.blockNum, pending.subs)
+}
+
+// notifyMined sends the mined notifications of a block, and checkpoints it once they are
+// delivered, so a restart replays any block whose events could still be lost.
+func (watcher *Watcher) notifyMined(blockNum string, subs []string) {
+	delivery := watcher.beginDelivery(blockNum)
+	defer watcher.endDelivery(delivery)
+
+	watcher.notifyForBlock(EventMined, blockNum, subs, delivery)
+}
+
+// dropPending removes a block from the confirmation queue, reporting whether it was queued.
+func (watcher *Watcher) dropPending(blockNum string) bool {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	for i, pending := range watcher.pendingConfirmations {
+		if pending.blockNum == blockNum {
+			watcher.pendingConfirmations = append(
+				watcher.pendingConfirmations[:i],
+				watcher.pendingConfirmations[i+1:]...,
+			)
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,214 @@
+package txnotify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is an event the Notifier failed to accept on every attempt.
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// DeadLetterStore keeps the events that could not be delivered, so they can be inspected
+// and replayed with ReplayDeadLetters.
+type DeadLetterStore interface {
+	AddDeadLetter(letter DeadLetter) error
+
+	// DeadLetters returns the stored dead letters, oldest first.
+	DeadLetters() ([]DeadLetter, error)
+
+	// RemoveDeadLetter deletes a dead letter, or returns ErrDeadLetterNotFound if there is none with id.
+	RemoveDeadLetter(id string) error
+}
+
+// ReplayDeadLetters passes every stored dead letter to notifier once, oldest first, and removes
+// the ones it accepts. It returns how many were delivered, along with the errors of the others.
+func ReplayDeadLetters(store DeadLetterStore, notifier Notifier) (int, error) {
+	letters, err := store.DeadLetters()
+	if err != nil {
+		return 0, fmt.Errorf("could not load dead letters: %w", err)
+	}
+
+	var (
+		delivered int
+		errs      []error
+	)
+
+	for _, letter := range letters {
+		if err := notifier.Notify(letter.Event); err != nil {
+			errs = append(errs, fmt.Errorf("could not deliver %s: %w", letter.ID, err))
+			continue
+		}
+
+		delivered++
+
+		if err := store.RemoveDeadLetter(letter.ID); err != nil {
+			errs = append(errs, fmt.Errorf("could not remove %s: %w", letter.ID, err))
+		}
+	}
+
+	return delivered, errors.Join(errs...)
+}
+
+// NewFileDeadLetterStore returns a DeadLetterStore that keeps dead letters in a file at path,
+// one JSON object per line.
+func NewFileDeadLetterStore(path string) *FileDeadLetterStore {
+	return &FileDeadLetterStore{path: path}
+}
+
+type FileDeadLetterStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// AddDeadLetter appends a line to the file, creating it if needed.
+func (store *FileDeadLetterStore) AddDeadLetter(letter DeadLetter) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("could not encode dead letter: %w", err)
+	}
+
+	const perm = 0o600
+
+	file, err := os.OpenFile(store.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("could not open dead letters: %w", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("could not write dead letter: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("could not sync dead letters: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not close dead letters: %w", err)
+	}
+
+	return nil
+}
+
+func (store *FileDeadLetterStore) DeadLetters() ([]DeadLetter, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.read()
+}
+
+// RemoveDeadLetter atomically replaces the file with one without the dead letter.
+func (store *FileDeadLetterStore) RemoveDeadLetter(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	letters, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	found := false
+
+	for _, letter := range letters {
+		if letter.ID == id {
+			found = true
+			continue
+		}
+
+		if err := encoder.Encode(letter); err != nil {
+			return fmt.Errorf("could not encode dead letter: %w", err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: '%s'", ErrDeadLetterNotFound, id)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write dead letters: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not sync dead letters: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close dead letters: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("could not replace dead letters: %w", err)
+	}
+
+	return nil
+}
+
+// read decodes every line of the file. A missing file has no dead letters.
+func (store *FileDeadLetterStore) read() ([]DeadLetter, error) {
+	file, err := os.Open(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open dead letters: %w", err)
+	}
+
+	defer file.Close()
+
+	var letters []DeadLetter
+
+	const maxLineSize = 16 << 20
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineSize)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		letter := DeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("could not decode dead letter: %w", err)
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read dead letters: %w", err)
+	}
+
+	return letters, nil
+}
@@ -0,0 +1,216 @@
+package txnotify
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+var errUnavailable = errors.New("receiver unavailable")
+
+// flakyNotifier fails the first failures calls to Notify, then records events in a recordingNotifier.
+type flakyNotifier struct {
+	*recordingNotifier
+
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (f *flakyNotifier) Notify(event Event) error {
+	f.mu.Lock()
+	f.attempts++
+	fail := f.attempts <= f.failures
+	f.mu.Unlock()
+
+	if fail {
+		return errUnavailable
+	}
+
+	return f.recordingNotifier.Notify(event)
+}
+
+func (f *flakyNotifier) attemptCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.attempts
+}
+
+func TestFileDeadLetterStore(t *testing.T) {
+	t.Run("it has no dead letters without a file", func(tt *testing.T) {
+		store := NewFileDeadLetterStore(filepath.Join(tt.TempDir(), "dead-letters.jsonl"))
+
+		letters, err := store.DeadLetters()
+		if err != nil || len(letters) != 0 {
+			tt.Fatalf("got %v (error: %v), want none", letters, err)
+		}
+	})
+
+	t.Run("it stores, lists and removes dead letters", func(tt *testing.T) {
+		path := filepath.Join(tt.TempDir(), "dead-letters.jsonl")
+
+		for _, id := range []string{"a", "b", "c"} {
+			letter := DeadLetter{ID: id, Event: Event{Kind: EventMined, Address: "0xa11ce"}, Attempts: 5, Error: "boom"}
+			if err := NewFileDeadLetterStore(path).AddDeadLetter(letter); err != nil {
+				tt.Fatalf("could not add dead letter: %v", err)
+			}
+		}
+
+		store := NewFileDeadLetterStore(path)
+
+		if err := store.RemoveDeadLetter("b"); err != nil {
+			tt.Fatalf("could not remove dead letter: %v", err)
+		}
+
+		if err := store.RemoveDeadLetter("b"); !errors.Is(err, ErrDeadLetterNotFound) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrDeadLetterNotFound)
+		}
+
+		letters, err := store.DeadLetters()
+		if err != nil {
+			tt.Fatalf("could not list dead letters: %v", err)
+		}
+
+		if len(letters) != 2 || letters[0].ID != "a" || letters[1].ID != "c" {
+			tt.Fatalf("got %+v, want a and c", letters)
+		}
+
+		if letters[0].Event.Address != "0xa11ce" || letters[0].Attempts != 5 || letters[0].Error != "boom" {
+			tt.Fatalf("dead letter was not stored as is, got %+v", letters[0])
+		}
+	})
+
+	t.Run("it replays dead letters", func(tt *testing.T) {
+		store := NewFileDeadLetterStore(filepath.Join(tt.TempDir(), "dead-letters.jsonl"))
+
+		for _, id := range []string{"a", "b"} {
+			event := Event{Kind: EventMined, Address: "0xa11ce", Transactions: []ethereum.Transaction{{Hash: "0xtx" + id}}}
+			if err := store.AddDeadLetter(DeadLetter{ID: id, Event: event}); err != nil {
+				tt.Fatalf("could not add dead letter: %v", err)
+			}
+		}
+
+		notifier := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 1}
+
+		delivered, err := ReplayDeadLetters(store, notifier)
+		if delivered != 1 || !errors.Is(err, errUnavailable) {
+			tt.Fatalf("got %d delivered (error: %v), want 1 and '%v'", delivered, err, errUnavailable)
+		}
+
+		if !notifier.has(recordedEvent{kind: EventMined, address: "0xa11ce", hash: "0xtxb"}) {
+			tt.Fatalf("replayed event was not delivered")
+		}
+
+		letters, err := store.DeadLetters()
+		if err != nil || len(letters) != 1 || letters[0].ID != "a" {
+			tt.Fatalf("got %+v (error: %v), want only the failed letter left", letters, err)
+		}
+	})
+}
+
+func TestWatcherDelivery(t *testing.T) {
+	const alice = "0xa11ce"
+
+	setup := func(tt *testing.T, notifier Notifier, backoff time.Duration) *Watcher {
+		tt.Helper()
+
+		chain := newMockChain()
+		chain.addBlock("0x10", "0xh10", "0xh9")
+		chain.setHead("0x10")
+
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.maxAttempts = 3
+		watcher.retryBackoff = backoff
+		watcher.maxRetryBackoff = 2 * backoff
+		watcher.deadLetters = NewFileDeadLetterStore(filepath.Join(tt.TempDir(), "dead-letters.jsonl"))
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		chain.addBlock("0x11", "0xh11", "0xh10", ethereum.Transaction{Hash: "0xtxa", From: alice})
+		chain.setHead("0x11")
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		return watcher
+	}
+
+	t.Run("it retries failed deliveries", func(tt *testing.T) {
+		notifier := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 2}
+		watcher := setup(tt, notifier, time.Millisecond)
+
+		notifier.waitFor(tt, EventMined, alice, "0xtxa")
+
+		if got := notifier.attemptCount(); got != 3 {
+			tt.Fatalf("got %d attempts, want 3", got)
+		}
+
+		if letters, _ := watcher.deadLetters.DeadLetters(); len(letters) != 0 {
+			tt.Fatalf("delivered events should not be dead letters, got %+v", letters)
+		}
+	})
+
+	t.Run("it stores events failing every attempt as dead letters", func(tt *testing.T) {
+		notifier := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 3}
+		watcher := setup(tt, notifier, time.Millisecond)
+
+		var letters []DeadLetter
+
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && len(letters) == 0; {
+			time.Sleep(5 * time.Millisecond)
+			letters, _ = watcher.deadLetters.DeadLetters()
+		}
+
+		if len(letters) != 1 {
+			tt.Fatalf("got %d dead letters, want 1", len(letters))
+		}
+
+		letter := letters[0]
+		if letter.Attempts != 3 || letter.Error != errUnavailable.Error() || letter.Event.Transactions[0].Hash != "0xtxa" {
+			tt.Fatalf("unexpected dead letter %+v", letter)
+		}
+
+		delivered, err := ReplayDeadLetters(watcher.deadLetters, notifier)
+		if delivered != 1 || err != nil {
+			tt.Fatalf("got %d delivered (error: %v), want 1", delivered, err)
+		}
+
+		notifier.waitFor(tt, EventMined, alice, "0xtxa")
+	})
+
+	t.Run("it stops retrying when closed", func(tt *testing.T) {
+		notifier := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 3}
+		watcher := setup(tt, notifier, time.Hour)
+
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && notifier.attemptCount() == 0; {
+			time.Sleep(time.Millisecond)
+		}
+
+		closed := make(chan struct{})
+
+		go func() {
+			watcher.Close()
+			close(closed)
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			tt.Fatalf("Close should not wait out the retry backoff")
+		}
+
+		letters, _ := watcher.deadLetters.DeadLetters()
+		if len(letters) != 1 || letters[0].Attempts != 1 {
+			tt.Fatalf("got %+v, want the interrupted event as a dead letter after 1 attempt", letters)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package txnotify
+
+import (
+	"fmt"
+	"time"
+)
+
+// deliver passes an event to the notifier, retrying failures with an exponential backoff
+// (retryBackoff, doubled on every retry up to maxRetryBackoff) until maxAttempts attempts
+// have been made, or the watcher stops. Events that are never accepted are stored as dead letters.
+func (watcher *Watcher) deliver(event Event) {
+	delivery := event.delivery
+	event.delivery = nil
+
+	defer watcher.settleDelivery(delivery)
+
+	backoff := watcher.retryBackoff
+
+	var (
+		err     error
+		attempt int
+	)
+
+	for attempt = 1; ; attempt++ {
+		if err = watcher.notifier.Notify(event); err == nil {
+			return
+		}
+
+		if attempt >= watcher.maxAttempts {
+			break
+		}
+
+		watcher.logger.Warn(
+			"could not deliver event, retrying",
+			"sequence", event.Sequence,
+			"address", event.Address,
+			"attempt", attempt,
+			"backoff", backoff,
+			"error", err,
+		)
+
+		if !watcher.waitBackoff(backoff) {
+			break
+		}
+
+		backoff = min(2*backoff, watcher.maxRetryBackoff)
+	}
+
+	watcher.logger.Error(
+		"could not deliver event, giving up",
+		"sequence", event.Sequence,
+		"address", event.Address,
+		"attempts", attempt,
+		"error", err,
+	)
+
+	watcher.deadLetter(event, attempt, err)
+}
+
+// waitBackoff pauses for backoff, reporting false if the watcher stopped in the meantime.
+func (watcher *Watcher) waitBackoff(backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-watcher.stopping():
+		return false
+	}
+}
+
+// stopping returns a channel that is closed once the watcher stops, see stop.
+func (watcher *Watcher) stopping() <-chan struct{} {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if watcher.stopped == nil {
+		watcher.stopped = make(chan struct{})
+	}
+
+	return watcher.stopped
+}
+
+// stop interrupts the retries of failed events, which are dead-lettered instead of waiting out
+// their backoff. It is called when the watcher is closed or its Listen context is done.
+func (watcher *Watcher) stop() {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if watcher.stopped == nil {
+		watcher.stopped = make(chan struct{})
+	}
+
+	select {
+	case <-watcher.stopped:
+	default:
+		close(watcher.stopped)
+	}
+}
+
+// dropEvent stores an event the dispatcher could not queue.
+func (watcher *Watcher) dropEvent(event Event, reason error) {
+	delivery := event.delivery
+	event.delivery = nil
+
+	defer watcher.settleDelivery(delivery)
+
+	watcher.logger.Warn("dropping event", "sequence", event.Sequence, "address", event.Address, "reason", reason)
+	watcher.deadLetter(event, 0, reason)
+}
+
+// deadLetter stores an event that could not be delivered. Without a DeadLetterStore it is discarded.
+func (watcher *Watcher) deadLetter(event Event, attempts int, cause error) {
+	if watcher.deadLetters == nil {
+		return
+	}
+
+	now := time.Now()
+
+	letter := DeadLetter{
+		ID:       fmt.Sprintf("%d-%d", now.UnixNano(), event.Sequence),
+		Event:    event,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: now,
+	}
+
+	if err := watcher.deadLetters.AddDeadLetter(letter); err != nil {
+		watcher.logger.Error("could not store dead letter", "sequence", event.Sequence, "error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package txnotify
+
+import (
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// attachContractAddresses sets ContractAddress on the contract creations of block, taking it from
+// the receipt when there is one and computing it from the sender and nonce otherwise.
+func (watcher *Watcher) attachContractAddresses(block *ethereum.Block) {
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+
+		if tx.To != nil {
+			continue
+		}
+
+		if tx.Receipt != nil && tx.Receipt.ContractAddress != nil {
+			tx.ContractAddress = tx.Receipt.ContractAddress
+			continue
+		}
+
+		addr, err := ethereum.ContractAddress(tx.From, tx.Nonce)
+		if err != nil {
+			watcher.logger.Debug("could not compute contract address", "hash", tx.Hash, "error", err)
+			continue
+		}
+
+		tx.ContractAddress = &addr
+	}
+}
+
+// notifyDeployments sends an EventDeployed notification for the contracts deployed in block by
+// addresses subscribed with Deployments, and subscribes to the contracts of those subscribed with
+// FollowDeployments, unless they are already followed. Failed creations, which deploy nothing,
+// are skipped.
+func (watcher *Watcher) notifyDeployments(block ethereum.Block, subs []string, delivery *blockDelivery) {
+	for _, addr := range subs {
+		notify, follow := watcher.deploymentModes(addr)
+		if !notify && !follow {
+			continue
+		}
+
+		var deployed []ethereum.Transaction
+
+		for _, tx := range block.Transactions {
+			if tx.To != nil || tx.ContractAddress == nil || NormalizeAddress(tx.From) != addr {
+				continue
+			}
+
+			if tx.Receipt != nil && !tx.Receipt.Succeeded() {
+				continue
+			}
+
+			deployed = append(deployed, tx)
+		}
+
+		if len(deployed) == 0 {
+			continue
+		}
+
+		if notify {
+			event := watcher.blockEvent(EventDeployed, addr, block)
+			event.Transactions = deployed
+			event.delivery = delivery
+			watcher.notify(event)
+		}
+
+		if !follow {
+			continue
+		}
+
+		// blocks can be notified again, e.g. after a reorg, so contracts that are already
+		// followed are not subscribed to twice.
+		for _, tx := range deployed {
+			added, err := watcher.addSubscription(Subscription{Address: *tx.ContractAddress}, true)
+			if err != nil {
+				watcher.logger.Error("could not subscribe to deployed contract", "contract", *tx.ContractAddress, "error", err)
+				continue
+			}
+
+			if added {
+				watcher.logger.Info("following deployed contract", "deployer", addr, "contract", *tx.ContractAddress)
+			}
+		}
+	}
+}
+
+// deploymentModes reports whether any subscription of address asks for deployment
+// notifications, and whether any asks to follow deployed contracts.
+func (watcher *Watcher) deploymentModes(address string) (bool, bool) {
+	var notify, follow bool
+
+	for _, sub := range watcher.subscriptionsFor(address) {
+		notify = notify || sub.Deployments
+		follow = follow || sub.FollowDeployments
+	}
+
+	return notify, follow
+}
@@ -0,0 +1,88 @@
+package txnotify
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func TestWatcherDeployments(t *testing.T) {
+	const (
+		deployer = "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0"
+		computed = "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d"
+		fromRcpt = "0xc0ffee"
+		alice    = "0xa11ce"
+	)
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.setHead("0x10")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+
+	sub := Subscription{Address: deployer, Deployments: true, FollowDeployments: true}
+	if err := watcher.Subscribe(sub); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	creation := ethereum.Transaction{Hash: "0xcreate", From: deployer, Nonce: "0x0"}
+	withReceipt := ethereum.Transaction{Hash: "0xcreate2", From: deployer, Nonce: "0x1"}
+	failed := ethereum.Transaction{Hash: "0xcreate3", From: deployer, Nonce: "0x2"}
+
+	receipt := successReceipt(withReceipt)
+	receipt.ContractAddress = ptr(fromRcpt)
+	chain.setReceipt(receipt)
+
+	receipt = successReceipt(failed)
+	receipt.Status = ethereum.ReceiptStatusFailure
+	chain.setReceipt(receipt)
+
+	chain.addBlock("0x11", "0xh11", "0xh10", creation, withReceipt, failed)
+	chain.setHead("0x11")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventDeployed, deployer, creation.Hash)
+	notifier.waitFor(t, EventDeployed, deployer, withReceipt.Hash)
+
+	if notifier.has(recordedEvent{kind: EventDeployed, address: deployer, hash: failed.Hash}) {
+		t.Fatalf("failed creations should not be notified as deployments")
+	}
+
+	for hash, want := range map[string]string{creation.Hash: computed, withReceipt.Hash: fromRcpt} {
+		tx := notifier.tx(hash)
+		if tx.ContractAddress == nil || *tx.ContractAddress != want {
+			t.Fatalf("(%s) got contract address %v, want %s", hash, tx.ContractAddress, want)
+		}
+	}
+
+	subscribed := subscribedAddresses(watcher)
+	for _, addr := range []string{computed, fromRcpt} {
+		if !slices.Contains(subscribed, addr) {
+			t.Fatalf("deployed contract %s should be followed, got %v", addr, subscribed)
+		}
+	}
+
+	chain.addBlock("0x12", "0xh12", "0xh11", ethereum.Transaction{Hash: "0xcall", From: alice, To: ptr(computed)})
+	chain.setHead("0x12")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventMined, computed, "0xcall")
+
+	// reprocessing the deployment, as after a reorg, does not take another reference.
+	watcher.notifyForBlock(EventMined, "0x11", []string{deployer}, nil)
+
+	if err := watcher.Unsubscribe(Subscription{Address: computed}); err != nil {
+		t.Fatalf("could not unsubscribe: %v", err)
+	}
+
+	if subscribed := subscribedAddresses(watcher); slices.Contains(subscribed, computed) {
+		t.Fatalf("deployed contract %s should be followed once, got %v", computed, subscribed)
+	}
+}
@@ -0,0 +1,332 @@
+package txnotify
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultDigestAttempts = 3
+
+// DigestOptions configures a Digester.
+type DigestOptions struct {
+	// Blocks and Interval send a digest once its events span Blocks blocks, or Interval after
+	// the first of them, whichever comes first. Zero disables either limit.
+	Blocks   int
+	Interval time.Duration
+
+	// MaxAttempts is how many times a digest is sent at the end of its interval, one interval
+	// apart, before it is given up on. Defaults to 3.
+	MaxAttempts int
+
+	// OnError is called with the digests given up on, and with those refused by the next
+	// Notifier on Close, for example to store them as dead letters. Defaults to logging them.
+	OnError func(event Event, err error)
+}
+
+// Digest aggregates the events of an address into a single Notify call, sent once they span
+// blocks blocks or interval after the first of them, whichever comes first; zero disables
+// either limit. See Digester.
+func Digest(blocks int, interval time.Duration) Middleware {
+	return func(next Notifier) Notifier {
+		if blocks <= 0 && interval <= 0 {
+			return next
+		}
+
+		return NewDigester(next, DigestOptions{Blocks: blocks, Interval: interval})
+	}
+}
+
+// Digester aggregates the events of an address into a single Notify call. The digest has the
+// kind, block and sequence of its last event and the contents of all of them. An event of
+// another kind sends the address's pending digest first, so events stay in order. A digest the
+// next Notifier refuses at the end of its interval is logged and tried again after another
+// interval, up to MaxAttempts times. With no interval, the last digest waits for more blocks.
+// Pending digests only live in memory: Close sends them on shutdown.
+type Digester struct {
+	next     Notifier
+	blocks   int
+	interval time.Duration
+	attempts int
+	onError  func(Event, error)
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	addresses map[string]*digestAddress
+	closed    bool
+}
+
+// NewDigester returns a Digester passing its digests to next.
+func NewDigester(next Notifier, options DigestOptions) *Digester {
+	attempts := options.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultDigestAttempts
+	}
+
+	logger := slog.Default()
+
+	onError := options.OnError
+	if onError == nil {
+		onError = func(event Event, err error) {
+			logger.Error(
+				"dropping digest",
+				"address", event.Address,
+				"sequence", event.Sequence,
+				"error", err,
+			)
+		}
+	}
+
+	return &Digester{
+		next:      next,
+		blocks:    options.Blocks,
+		interval:  options.Interval,
+		attempts:  attempts,
+		onError:   onError,
+		logger:    logger,
+		addresses: make(map[string]*digestAddress),
+	}
+}
+
+// digest holds the events of an address waiting to be sent together.
+type digest struct {
+	events   []Event
+	timer    *time.Timer
+	attempts int
+}
+
+// blockCount returns how many blocks the events span, counting events without a block once.
+func (d *digest) blockCount() int {
+	count := 0
+
+	for i, event := range d.events {
+		if event.BlockNumber == "" || i == 0 || event.BlockNumber != d.events[i-1].BlockNumber {
+			count++
+		}
+	}
+
+	return count
+}
+
+// merged returns the last event with the contents of all of them.
+func (d *digest) merged() Event {
+	event := d.events[len(d.events)-1]
+
+	if len(d.events) == 1 {
+		return event
+	}
+
+	event.Transactions = nil
+	event.TokenTransfers = nil
+	event.NFTTransfers = nil
+
+	for _, e := range d.events {
+		event.Transactions = append(event.Transactions, e.Transactions...)
+		event.TokenTransfers = append(event.TokenTransfers, e.TokenTransfers...)
+		event.NFTTransfers = append(event.NFTTransfers, e.NFTTransfers...)
+	}
+
+	return event
+}
+
+// digestAddress holds the pending digest of an address. Its lock is held while the address's
+// digests are passed to the next Notifier, so they cannot overtake each other, without holding
+// up the other addresses.
+type digestAddress struct {
+	sending sync.Mutex
+	users   int
+	pending *digest
+}
+
+// acquire locks the state of address, creating it if needed. It must be released with release.
+func (d *Digester) acquire(address string) *digestAddress {
+	d.mu.Lock()
+
+	state, ok := d.addresses[address]
+	if !ok {
+		state = &digestAddress{}
+		d.addresses[address] = state
+	}
+
+	state.users++
+	d.mu.Unlock()
+
+	state.sending.Lock()
+
+	return state
+}
+
+// release unlocks the state of address, forgetting it once no digest is pending.
+func (d *Digester) release(address string, state *digestAddress) {
+	state.sending.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state.users--
+
+	if state.users == 0 && state.pending == nil {
+		delete(d.addresses, address)
+	}
+}
+
+// Notify adds the event to its address's digest, sending it if it spans enough blocks. The
+// event is taken back out if that fails, so the Watcher's retry adds it again.
+func (d *Digester) Notify(event Event) error {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+
+	if closed {
+		return ErrNotifierClosed
+	}
+
+	state := d.acquire(event.Address)
+	defer d.release(event.Address, state)
+
+	pending := state.pending
+
+	if pending != nil && pending.events[0].Kind != event.Kind {
+		if err := d.send(state); err != nil {
+			return err
+		}
+
+		pending = nil
+	}
+
+	if pending == nil {
+		pending = &digest{}
+		state.pending = pending
+
+		if d.interval > 0 {
+			pending.timer = time.AfterFunc(d.interval, func() { d.expire(event.Address, pending) })
+		}
+	}
+
+	pending.events = append(pending.events, event)
+
+	if d.blocks <= 0 || pending.blockCount() < d.blocks {
+		return nil
+	}
+
+	if err := d.send(state); err != nil {
+		pending.events = pending.events[:len(pending.events)-1]
+
+		if len(pending.events) == 0 {
+			d.discard(state)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// expire sends a digest at the end of its interval, unless it was already sent. It is given
+// up on once it was refused MaxAttempts times.
+func (d *Digester) expire(address string, pending *digest) {
+	state := d.acquire(address)
+	defer d.release(address, state)
+
+	if state.pending != pending {
+		return
+	}
+
+	pending.attempts++
+
+	err := d.send(state)
+	if err == nil {
+		return
+	}
+
+	if pending.attempts >= d.attempts {
+		d.discard(state)
+		d.onError(pending.merged(), fmt.Errorf("digest refused %d times: %w", pending.attempts, err))
+
+		return
+	}
+
+	d.logger.Warn(
+		"could not send digest, retrying",
+		"address", address,
+		"events", len(pending.events),
+		"attempt", pending.attempts,
+		"retryIn", d.interval,
+		"error", err,
+	)
+
+	pending.timer.Reset(d.interval)
+}
+
+// Flush sends every pending digest right away. Digests the next Notifier refuses stay pending.
+func (d *Digester) Flush() error {
+	var errs []error
+
+	d.each(func(state *digestAddress) {
+		if err := d.send(state); err != nil {
+			errs = append(errs, err)
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// Close stops accepting events and sends every pending digest, passing those the next Notifier
+// refuses to OnError.
+func (d *Digester) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.each(func(state *digestAddress) {
+		pending := state.pending
+
+		if err := d.send(state); err != nil {
+			d.discard(state)
+			d.onError(pending.merged(), err)
+		}
+	})
+}
+
+// each calls fn with the state of every address with a pending digest, holding its lock.
+func (d *Digester) each(fn func(state *digestAddress)) {
+	d.mu.Lock()
+	addresses := slices.Sorted(maps.Keys(d.addresses))
+	d.mu.Unlock()
+
+	for _, address := range addresses {
+		state := d.acquire(address)
+
+		if state.pending != nil {
+			fn(state)
+		}
+
+		d.release(address, state)
+	}
+}
+
+// send passes the pending digest of an address to the next Notifier, discarding it if accepted.
+// Callers must hold the address's lock, see acquire.
+func (d *Digester) send(state *digestAddress) error {
+	if err := d.next.Notify(state.pending.merged()); err != nil {
+		return err
+	}
+
+	d.discard(state)
+
+	return nil
+}
+
+// discard forgets the pending digest of an address and stops its timer. Callers must hold the
+// address's lock, see acquire.
+func (d *Digester) discard(state *digestAddress) {
+	if state.pending.timer != nil {
+		state.pending.timer.Stop()
+	}
+
+	state.pending = nil
+}
@@ -0,0 +1,183 @@
+package txnotify
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull     = errors.New("delivery queue is full")
+	ErrWatcherClosed = errors.New("watcher is closed")
+)
+
+// DropPolicy decides what happens to a new event when its delivery queue is full.
+type DropPolicy string
+
+const (
+	// DropNone blocks the watcher until the queue has room, slowing block processing
+	// down to the notifier's pace. Events for the other queues are not held up.
+	DropNone DropPolicy = "none"
+
+	// DropNewest discards the new event.
+	DropNewest DropPolicy = "newest"
+
+	// DropOldest discards the oldest queued event to make room for the new one.
+	DropOldest DropPolicy = "oldest"
+)
+
+// dispatcher delivers events with a fixed pool of workers, each with its own bounded queue.
+// Events are assigned to workers by address, so the events of an address are delivered one
+// at a time and in the order they were enqueued.
+type dispatcher struct {
+	// sending serializes the senders of each queue, and is always taken before mu. A sender
+	// blocked on a full queue only holds its queue's lock, so the other queues keep flowing.
+	sending []sync.Mutex
+
+	mu       sync.Mutex
+	queues   []chan Event
+	policy   DropPolicy
+	sequence uint64
+	closed   bool
+	wg       sync.WaitGroup
+
+	deliver func(Event)
+	drop    func(Event, error)
+}
+
+// newDispatcher starts workers goroutines sharing queueSize slots. drop is called with the
+// events discarded by the policy, or enqueued after close.
+func newDispatcher(
+	workers, queueSize int,
+	policy DropPolicy,
+	deliver func(Event),
+	drop func(Event, error),
+) *dispatcher {
+	workers = max(workers, 1)
+
+	d := &dispatcher{
+		sending: make([]sync.Mutex, workers),
+		queues:  make([]chan Event, workers),
+		policy:  policy,
+		deliver: deliver,
+		drop:    drop,
+	}
+
+	for i := range d.queues {
+		queue := make(chan Event, max(queueSize/workers, 1))
+		d.queues[i] = queue
+
+		d.wg.Add(1)
+
+		go func() {
+			defer d.wg.Done()
+
+			for event := range queue {
+				d.deliver(event)
+			}
+		}()
+	}
+
+	return d
+}
+
+// enqueue numbers an event and queues it for delivery, applying the drop policy if its
+// queue is full. Dropped events keep their number, so consumers can detect the gap.
+func (d *dispatcher) enqueue(event Event) {
+	shard := d.shard(event.Address)
+
+	d.sending[shard].Lock()
+	defer d.sending[shard].Unlock()
+
+	d.mu.Lock()
+	d.sequence++
+	event.Sequence = d.sequence
+	event.SentAt = time.Now()
+	closed := d.closed
+	d.mu.Unlock()
+
+	d.push(shard, event, closed)
+}
+
+// forward queues an event as is, keeping the number and send time it already has.
+func (d *dispatcher) forward(event Event) {
+	shard := d.shard(event.Address)
+
+	d.sending[shard].Lock()
+	defer d.sending[shard].Unlock()
+
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+
+	d.push(shard, event, closed)
+}
+
+// push queues an event on a shard, applying the drop policy if its queue is full. Callers must
+// hold the shard's sending lock, which keeps close from closing the queue under them.
+func (d *dispatcher) push(shard int, event Event, closed bool) {
+	if closed {
+		d.drop(event, ErrWatcherClosed)
+		return
+	}
+
+	queue := d.queues[shard]
+
+	switch d.policy {
+	case DropNewest:
+		select {
+		case queue <- event:
+		default:
+			d.drop(event, ErrQueueFull)
+		}
+
+	case DropOldest:
+		for {
+			select {
+			case queue <- event:
+				return
+			default:
+			}
+
+			select {
+			case oldest := <-queue:
+				d.drop(oldest, ErrQueueFull)
+			default:
+			}
+		}
+
+	default:
+		queue <- event
+	}
+}
+
+// shard returns the index of the worker delivering the events of address.
+func (d *dispatcher) shard(address string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(address))
+
+	return int(hash.Sum32() % uint32(len(d.queues)))
+}
+
+// close stops accepting events and waits for the queued ones to be delivered.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+
+	d.closed = true
+	d.mu.Unlock()
+
+	// senders that saw the dispatcher open finish their push before their queue is closed.
+	for i, queue := range d.queues {
+		d.sending[i].Lock()
+		close(queue)
+		d.sending[i].Unlock()
+	}
+
+	d.wg.Wait()
+}
@@ -0,0 +1,261 @@
+package txnotify
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// gatedDelivery records delivered events, blocking every delivery until the gate is opened.
+type gatedDelivery struct {
+	gate chan struct{}
+
+	mu        sync.Mutex
+	delivered []Event
+	dropped   []Event
+	reasons   []error
+}
+
+func newGatedDelivery() *gatedDelivery {
+	return &gatedDelivery{gate: make(chan struct{})}
+}
+
+func (g *gatedDelivery) deliver(event Event) {
+	<-g.gate
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.delivered = append(g.delivered, event)
+}
+
+func (g *gatedDelivery) drop(event Event, reason error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.dropped = append(g.dropped, event)
+	g.reasons = append(g.reasons, reason)
+}
+
+func (g *gatedDelivery) sequences(events func(*gatedDelivery) []Event) []uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var seqs []uint64
+	for _, event := range events(g) {
+		seqs = append(seqs, event.Sequence)
+	}
+
+	return seqs
+}
+
+func delivered(g *gatedDelivery) []Event { return g.delivered }
+
+func dropped(g *gatedDelivery) []Event { return g.dropped }
+
+func testEvent(address string) Event {
+	return Event{Kind: EventMined, Address: address, Transactions: []ethereum.Transaction{{Hash: "0xtx"}}}
+}
+
+func TestDispatcher(t *testing.T) {
+	// with a single slot, the first event is taken by the worker and the second waits in the queue.
+	fill := func(d *dispatcher) {
+		d.enqueue(testEvent("0xa11ce"))
+
+		for deadline := time.Now().Add(time.Second); len(d.queues[0]) > 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+
+		d.enqueue(testEvent("0xa11ce"))
+	}
+
+	t.Run("it delivers the events of an address in order", func(tt *testing.T) {
+		const addresses, perAddress = 8, 50
+
+		var (
+			mu   sync.Mutex
+			last = make(map[string]uint64)
+			errs []error
+		)
+
+		d := newDispatcher(4, 16, DropNone, func(event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if event.Sequence <= last[event.Address] {
+				errs = append(errs, fmt.Errorf("%s: got %d after %d", event.Address, event.Sequence, last[event.Address]))
+			}
+
+			last[event.Address] = event.Sequence
+		}, func(Event, error) {})
+
+		for i := range addresses * perAddress {
+			d.enqueue(testEvent(fmt.Sprintf("0x%x", i%addresses)))
+		}
+
+		d.close()
+
+		if len(errs) > 0 {
+			tt.Fatalf("events delivered out of order: %v", errors.Join(errs...))
+		}
+
+		if len(last) != addresses {
+			tt.Fatalf("got events for %d addresses, want %d", len(last), addresses)
+		}
+	})
+
+	t.Run("it blocks when the queue is full", func(tt *testing.T) {
+		g := newGatedDelivery()
+		d := newDispatcher(1, 1, DropNone, g.deliver, g.drop)
+
+		fill(d)
+
+		done := make(chan struct{})
+
+		go func() {
+			d.enqueue(testEvent("0xa11ce"))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			tt.Fatalf("enqueue should block while the queue is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(g.gate)
+		<-done
+		d.close()
+
+		if got := g.sequences(delivered); len(got) != 3 {
+			tt.Fatalf("got %v delivered, want all 3 events", got)
+		}
+	})
+
+	t.Run("it drops the newest events when the queue is full", func(tt *testing.T) {
+		g := newGatedDelivery()
+		d := newDispatcher(1, 1, DropNewest, g.deliver, g.drop)
+
+		fill(d)
+		d.enqueue(testEvent("0xa11ce"))
+
+		close(g.gate)
+		d.close()
+
+		if got := g.sequences(dropped); len(got) != 1 || got[0] != 3 {
+			tt.Fatalf("got %v dropped, want [3]", got)
+		}
+
+		if got := g.sequences(delivered); len(got) != 2 || got[1] != 2 {
+			tt.Fatalf("got %v delivered, want [1 2]", got)
+		}
+
+		if !errors.Is(g.reasons[0], ErrQueueFull) {
+			tt.Fatalf("got: '%v', want: '%v'", g.reasons[0], ErrQueueFull)
+		}
+	})
+
+	t.Run("it drops the oldest events when the queue is full", func(tt *testing.T) {
+		g := newGatedDelivery()
+		d := newDispatcher(1, 1, DropOldest, g.deliver, g.drop)
+
+		fill(d)
+		d.enqueue(testEvent("0xa11ce"))
+
+		close(g.gate)
+		d.close()
+
+		if got := g.sequences(dropped); len(got) != 1 || got[0] != 2 {
+			tt.Fatalf("got %v dropped, want [2]", got)
+		}
+
+		if got := g.sequences(delivered); len(got) != 2 || got[1] != 3 {
+			tt.Fatalf("got %v delivered, want [1 3]", got)
+		}
+	})
+
+	t.Run("it keeps other queues flowing while one is full", func(tt *testing.T) {
+		const slow = "0xa11ce"
+
+		gate := make(chan struct{})
+
+		var (
+			mu        sync.Mutex
+			delivered []string
+		)
+
+		d := newDispatcher(2, 2, DropNone, func(event Event) {
+			if event.Address == slow {
+				<-gate
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			delivered = append(delivered, event.Address)
+		}, func(Event, error) {})
+
+		fast := ""
+		for i := 0; fast == ""; i++ {
+			if addr := fmt.Sprintf("0x%x", i); d.shard(addr) != d.shard(slow) {
+				fast = addr
+			}
+		}
+
+		// the first event is taken by the worker, the second fills the queue and the third blocks.
+		blocked := make(chan struct{})
+
+		go func() {
+			for range 3 {
+				d.enqueue(testEvent(slow))
+			}
+
+			close(blocked)
+		}()
+
+		enqueued := make(chan struct{})
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			d.enqueue(testEvent(fast))
+			close(enqueued)
+		}()
+
+		select {
+		case <-enqueued:
+		case <-time.After(time.Second):
+			tt.Fatalf("enqueue for another queue should not wait for the full one")
+		}
+
+		select {
+		case <-blocked:
+			tt.Fatalf("enqueue should block while the queue is full")
+		default:
+		}
+
+		close(gate)
+		<-blocked
+		d.close()
+
+		if len(delivered) != 4 {
+			tt.Fatalf("got %v delivered, want 4 events", delivered)
+		}
+	})
+
+	t.Run("it drops events enqueued after close", func(tt *testing.T) {
+		g := newGatedDelivery()
+		close(g.gate)
+
+		d := newDispatcher(1, 1, DropNone, g.deliver, g.drop)
+		d.close()
+		d.enqueue(testEvent("0xa11ce"))
+
+		if len(g.reasons) != 1 || !errors.Is(g.reasons[0], ErrWatcherClosed) {
+			tt.Fatalf("got %v, want '%v'", g.reasons, ErrWatcherClosed)
+		}
+	})
+}
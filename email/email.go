@@ -0,0 +1,470 @@
+// Package email implements a txnotify.Notifier that emails the events of an address to its
+// recipients, rendered with a template per event kind and batched within a time window.
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+)
+
+var (
+	ErrInvalidRecipient    = errors.New("invalid recipient")
+	ErrStartTLSUnsupported = errors.New("smtp server does not support STARTTLS")
+)
+
+type Options struct {
+	// Addr is the SMTP server's host:port.
+	Addr string
+
+	// From is the sender of the messages.
+	From string
+
+	// Username and Password authenticate with AUTH PLAIN when Username is set. Go's SMTP client
+	// only sends them over TLS or to localhost.
+	Username string
+	Password string
+
+	// StartTLS upgrades the connection with STARTTLS, failing if the server does not support it.
+	StartTLS  bool
+	TLSConfig *tls.Config
+
+	// Templates holds the template of each event kind, DefaultTemplate is used for the others.
+	// HTML sends the rendered bodies as text/html instead of text/plain.
+	Templates map[txnotify.EventKind]Template
+	HTML      bool
+
+	// Window is how long the events of an address are collected before they are sent as one
+	// message. Zero sends every event right away.
+	Window time.Duration
+
+	// MaxAttempts is how many windows a batched message is sent in before it is given up on.
+	// Defaults to 3.
+	MaxAttempts int
+
+	// OnError is called with the events of the batched messages given up on, for example to
+	// store them as dead letters. Defaults to logging them.
+	OnError func(events []txnotify.Event, err error)
+
+	// Timeout bounds each SMTP session.
+	Timeout time.Duration
+}
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxAttempts = 3
+)
+
+// batch collects the events of one kind for one address until its window ends.
+type batch struct {
+	events   []txnotify.Event
+	timer    *time.Timer
+	attempts int
+}
+
+type batchKey struct {
+	address string
+	kind    txnotify.EventKind
+}
+
+type Notifier struct {
+	mu         sync.Mutex
+	recipients map[string][]mail.Address
+	batches    map[batchKey]*batch
+	closed     bool
+
+	// sending counts the batches whose window ended and that are being sent, so Close can wait for them.
+	sending sync.WaitGroup
+
+	options Options
+	host    string
+	logger  *slog.Logger
+
+	// sender is options.From parsed: its bare address goes in the SMTP envelope, and the display
+	// form in the From header.
+	sender *mail.Address
+}
+
+func NewNotifier(options Options) (*Notifier, error) {
+	host, _, err := net.SplitHostPort(options.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address '%s': %w", options.Addr, err)
+	}
+
+	sender, err := mail.ParseAddress(options.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender '%s': %w", options.From, err)
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = defaultTimeout
+	}
+
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = defaultMaxAttempts
+	}
+
+	logger := slog.Default()
+
+	if options.OnError == nil {
+		options.OnError = func(events []txnotify.Event, err error) {
+			logger.Error("dropping email", "events", len(events), "error", err)
+		}
+	}
+
+	return &Notifier{
+		recipients: make(map[string][]mail.Address),
+		batches:    make(map[batchKey]*batch),
+		options:    options,
+		host:       host,
+		logger:     logger,
+		sender:     sender,
+	}, nil
+}
+
+// AddRecipients registers the email addresses that get the events of address, or of every address
+// with txnotify.AnyAddress. They can have a display name (e.g. "Alice <alice@example.com>"), which
+// is only used in the To header.
+func (n *Notifier) AddRecipients(address string, recipients ...string) error {
+	parsed := make([]mail.Address, 0, len(recipients))
+
+	for _, recipient := range recipients {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("%w: '%s'", ErrInvalidRecipient, recipient)
+		}
+
+		parsed = append(parsed, *addr)
+	}
+
+	if address != txnotify.AnyAddress {
+		address = txnotify.NormalizeAddress(address)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	all := append(n.recipients[address], parsed...)
+
+	slices.SortStableFunc(all, func(a, b mail.Address) int { return strings.Compare(a.Address, b.Address) })
+
+	n.recipients[address] = slices.CompactFunc(all, func(a, b mail.Address) bool { return a.Address == b.Address })
+
+	return nil
+}
+
+// Notify emails the event to the recipients of its address. With a Window, the event is added
+// to the address's pending message of that kind, which is sent once the window ends; failures
+// are then logged and the message is sent again at the end of the next window, up to MaxAttempts
+// times.
+func (n *Notifier) Notify(event txnotify.Event) error {
+	n.mu.Lock()
+
+	if n.closed {
+		n.mu.Unlock()
+		return txnotify.ErrNotifierClosed
+	}
+
+	if len(n.recipientsOf(event.Address)) == 0 {
+		n.mu.Unlock()
+		return nil
+	}
+
+	if n.options.Window <= 0 {
+		n.mu.Unlock()
+		return n.send(event.Address, event.Kind, []txnotify.Event{event})
+	}
+
+	key := batchKey{address: event.Address, kind: event.Kind}
+	n.add(key, event)
+	n.mu.Unlock()
+
+	return nil
+}
+
+// add appends events to a batch, starting its window if it is new. Callers must hold mu.
+func (n *Notifier) add(key batchKey, events ...txnotify.Event) *batch {
+	pending, ok := n.batches[key]
+	if !ok {
+		pending = &batch{}
+		pending.timer = time.AfterFunc(n.options.Window, func() { n.flushBatch(key) })
+		n.batches[key] = pending
+	}
+
+	pending.events = append(pending.events, events...)
+
+	return pending
+}
+
+// flushBatch sends a batch whose window ended, queueing its events again if that fails, ahead
+// of those added since. A batch failing MaxAttempts times is passed to OnError instead.
+func (n *Notifier) flushBatch(key batchKey) {
+	n.mu.Lock()
+
+	// once closed, Close sends the batches left.
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+
+	pending, ok := n.batches[key]
+	delete(n.batches, key)
+
+	if ok {
+		n.sending.Add(1)
+	}
+
+	n.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	defer n.sending.Done()
+
+	err := n.send(key.address, key.kind, pending.events)
+	if err == nil {
+		return
+	}
+
+	pending.attempts++
+
+	n.mu.Lock()
+	requeue := !n.closed && pending.attempts < n.options.MaxAttempts
+
+	if requeue {
+		newer := n.batches[key]
+		delete(n.batches, key)
+
+		retry := n.add(key, pending.events...)
+		retry.attempts = pending.attempts
+
+		if newer != nil {
+			newer.timer.Stop()
+			retry.events = append(retry.events, newer.events...)
+		}
+	}
+	n.mu.Unlock()
+
+	if requeue {
+		n.logger.Error(
+			"could not send email, retrying in the next window",
+			"address", key.address,
+			"attempt", pending.attempts,
+			"error", err,
+		)
+
+		return
+	}
+
+	n.options.OnError(pending.events, fmt.Errorf("could not send email after %d attempts: %w", pending.attempts, err))
+}
+
+// Flush sends every pending message right away.
+func (n *Notifier) Flush() error {
+	n.mu.Lock()
+	batches := n.batches
+	n.batches = make(map[batchKey]*batch)
+	n.mu.Unlock()
+
+	var errs []error
+
+	for key, pending := range batches {
+		pending.timer.Stop()
+
+		if err := n.send(key.address, key.kind, pending.events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close stops accepting events, stops the pending windows and sends their messages right away,
+// returning the errors of those that could not be sent. It waits for the messages already being
+// sent at the end of their window.
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	err := n.Flush()
+
+	n.sending.Wait()
+
+	return err
+}
+
+// recipientsOf returns the recipients of address and of txnotify.AnyAddress, once per email
+// address. Callers must hold mu.
+func (n *Notifier) recipientsOf(address string) []mail.Address {
+	recipients := slices.Clone(n.recipients[address])
+
+	for _, recipient := range n.recipients[txnotify.AnyAddress] {
+		if !slices.ContainsFunc(recipients, func(r mail.Address) bool { return r.Address == recipient.Address }) {
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	return recipients
+}
+
+// send renders the events into one message and sends it to the recipients of address.
+func (n *Notifier) send(address string, kind txnotify.EventKind, events []txnotify.Event) error {
+	n.mu.Lock()
+	recipients := n.recipientsOf(address)
+	n.mu.Unlock()
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	msg, err := n.render(newMessage(address, kind, events), recipients)
+	if err != nil {
+		return err
+	}
+
+	if err := n.deliver(recipients, msg); err != nil {
+		return fmt.Errorf("could not email %s notification for %s: %w", kind, address, err)
+	}
+
+	return nil
+}
+
+func newMessage(address string, kind txnotify.EventKind, events []txnotify.Event) Message {
+	msg := Message{Kind: kind, Address: address, Events: events}
+
+	for _, event := range events {
+		msg.Transactions = append(msg.Transactions, event.Transactions...)
+		msg.TokenTransfers = append(msg.TokenTransfers, event.TokenTransfers...)
+		msg.NFTTransfers = append(msg.NFTTransfers, event.NFTTransfers...)
+	}
+
+	return msg
+}
+
+// render builds the headers and body of a message.
+func (n *Notifier) render(msg Message, recipients []mail.Address) ([]byte, error) {
+	tmpl, ok := n.options.Templates[msg.Kind]
+	if !ok {
+		tmpl = DefaultTemplate
+	}
+
+	subject := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(subject, "subject", msg); err != nil {
+		return nil, fmt.Errorf("could not render subject: %w", err)
+	}
+
+	body := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(body, "body", msg); err != nil {
+		return nil, fmt.Errorf("could not render body: %w", err)
+	}
+
+	contentType := "text/plain"
+	if n.options.HTML {
+		contentType = "text/html"
+	}
+
+	buf := &bytes.Buffer{}
+
+	to := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		to[i] = recipient.String()
+	}
+
+	fmt.Fprintf(buf, "From: %s\r\n", n.sender.String())
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n\r\n", contentType)
+
+	// SMTP requires CRLF line endings.
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
+
+// deliver runs an SMTP session sending msg to recipients, with their bare addresses in the envelope.
+func (n *Notifier) deliver(recipients []mail.Address, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", n.options.Addr, n.options.Timeout)
+	if err != nil {
+		return fmt.Errorf("could not connect: %w", err)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(n.options.Timeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("could not set deadline: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("could not start session: %w", err)
+	}
+
+	defer client.Close()
+
+	if n.options.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return ErrStartTLSUnsupported
+		}
+
+		config := &tls.Config{ServerName: n.host, MinVersion: tls.VersionTLS12}
+		if n.options.TLSConfig != nil {
+			config = n.options.TLSConfig
+		}
+
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("could not start tls: %w", err)
+		}
+	}
+
+	if n.options.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.options.Username, n.options.Password, n.host)); err != nil {
+			return fmt.Errorf("could not authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.sender.Address); err != nil {
+		return fmt.Errorf("could not set sender: %w", err)
+	}
+
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return fmt.Errorf("could not add recipient %s: %w", recipient.Address, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("could not start data: %w", err)
+	}
+
+	if _, err := writer.Write(msg); err != nil {
+		writer.Close()
+		return fmt.Errorf("could not write message: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("could not send message: %w", err)
+	}
+
+	if err := client.Quit(); err != nil {
+		return fmt.Errorf("could not quit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,611 @@
+package email
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+const (
+	alice = "0xa11ce"
+	from  = "txnotify@example.com"
+	to    = "alice@example.com"
+)
+
+// received is a message accepted by the fake SMTP server.
+type received struct {
+	from string
+	to   []string
+	data string
+	tls  bool
+	auth string
+}
+
+// smtpServer is a minimal SMTP server accepting every message, optionally offering STARTTLS
+// and rejecting the first failures MAIL commands.
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+
+	mu       sync.Mutex
+	failures int
+	messages []received
+}
+
+func newSMTPServer(t *testing.T, tlsConfig *tls.Config) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	server := &smtpServer{listener: listener, tls: tlsConfig}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *smtpServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) received() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]received(nil), s.messages...)
+}
+
+// waitFor polls until the server received n messages.
+func (s *smtpServer) waitFor(t *testing.T, n int) []received {
+	t.Helper()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if got := s.received(); len(got) >= n {
+			return got
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("got %d messages, want %d", len(s.received()), n)
+
+	return nil
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	msg := received{}
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost")
+
+			if s.tls != nil && !msg.tls {
+				reply("250-STARTTLS")
+			}
+
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			reader = textproto.NewReader(bufio.NewReader(conn))
+			msg.tls = true
+		case "AUTH":
+			credentials, _ := strings.CutPrefix(arg, "PLAIN ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			msg.auth = string(decoded)
+
+			reply("235 authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			fail := s.failures > 0
+			s.failures--
+			s.mu.Unlock()
+
+			if fail {
+				reply("550 rejected")
+				continue
+			}
+
+			msg.from = arg
+			reply("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, arg)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+
+			data, err := io.ReadAll(reader.DotReader())
+			if err != nil {
+				return
+			}
+
+			msg.data = string(data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// selfSigned returns server and client TLS configs for a certificate valid for 127.0.0.1.
+func selfSigned(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "txnotify test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, cert, cert, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+
+	return server, client
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testEvent(kind txnotify.EventKind, hash string) txnotify.Event {
+	return txnotify.Event{
+		Version:     txnotify.EventVersion,
+		Kind:        kind,
+		Address:     alice,
+		BlockNumber: "0x11",
+		Transactions: []ethereum.Transaction{
+			{
+				Hash:        hash,
+				From:        alice,
+				To:          ptr("0xb0b"),
+				Value:       "0xde0b6b3a7640000",
+				BlockNumber: ptr("0x11"),
+				Receipt:     &ethereum.Receipt{Status: ethereum.ReceiptStatusSuccess},
+			},
+		},
+		TokenTransfers: []ethereum.TokenTransfer{
+			{Token: "0x70ce", From: alice, To: "0xb0b", Amount: "0x64", TransactionHash: hash},
+		},
+	}
+}
+
+func setup(t *testing.T, server *smtpServer, options Options) *Notifier {
+	t.Helper()
+
+	options.Addr = server.addr()
+	options.From = from
+
+	notifier, err := NewNotifier(options)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	if err := notifier.AddRecipients("0xA11CE", to); err != nil {
+		t.Fatalf("could not add recipient: %v", err)
+	}
+
+	return notifier
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("it emails events with the default template", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		msg := server.waitFor(tt, 1)[0]
+
+		if msg.from != "FROM:<"+from+">" || len(msg.to) != 1 || msg.to[0] != "TO:<"+to+">" {
+			tt.Fatalf("got envelope %s -> %v, want %s -> %s", msg.from, msg.to, from, to)
+		}
+
+		for _, want := range []string{
+			"Subject: [txnotify] 1 mined notification(s) for " + alice,
+			"Content-Type: text/plain",
+			"Transaction 0xtxa",
+			"Value: 1 ETH",
+			"Block: 17",
+			"Status: succeeded",
+			"Amount: 100",
+		} {
+			if !strings.Contains(msg.data, want) {
+				tt.Fatalf("message is missing '%s':\n%s", want, msg.data)
+			}
+		}
+	})
+
+	t.Run("it keeps display names out of the envelope", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+
+		notifier, err := NewNotifier(Options{Addr: server.addr(), From: "Treasury <" + from + ">"})
+		if err != nil {
+			tt.Fatalf("could not create notifier: %v", err)
+		}
+
+		if err := notifier.AddRecipients(alice, "Bob <bob@example.com>", "bob@example.com"); err != nil {
+			tt.Fatalf("could not add recipients: %v", err)
+		}
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		msg := server.waitFor(tt, 1)[0]
+
+		if msg.from != "FROM:<"+from+">" || len(msg.to) != 1 || msg.to[0] != "TO:<bob@example.com>" {
+			tt.Fatalf("got envelope %s -> %v, want %s -> bob@example.com", msg.from, msg.to, from)
+		}
+
+		for _, want := range []string{
+			"From: \"Treasury\" <" + from + ">",
+			"To: \"Bob\" <bob@example.com>",
+		} {
+			if !strings.Contains(msg.data, want) {
+				tt.Fatalf("message is missing '%s':\n%s", want, msg.data)
+			}
+		}
+	})
+
+	t.Run("it emails the events of every address to AnyAddress recipients once", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{})
+
+		if err := notifier.AddRecipients(txnotify.AnyAddress, to, "ops@example.com"); err != nil {
+			tt.Fatalf("could not add recipients: %v", err)
+		}
+
+		bob := testEvent(txnotify.EventMined, "0xtxb")
+		bob.Address = "0xb0b"
+
+		for _, event := range []txnotify.Event{testEvent(txnotify.EventMined, "0xtxa"), bob} {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		for _, msg := range server.waitFor(tt, 2) {
+			if len(msg.to) != 2 {
+				tt.Fatalf("got recipients %v, want %s and ops@example.com once", msg.to, to)
+			}
+		}
+	})
+
+	t.Run("it renders the template of each event kind", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{
+			HTML: true,
+			Templates: map[txnotify.EventKind]Template{
+				txnotify.EventPending: htmltemplate.Must(htmltemplate.New("pending").Parse(
+					`{{define "subject"}}Pending for {{.Address}}{{end}}` +
+						`{{define "body"}}{{range .Transactions}}<p>{{.Hash}}</p>{{end}}{{end}}`,
+				)),
+			},
+		})
+
+		if err := notifier.Notify(testEvent(txnotify.EventPending, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxb")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		got := server.waitFor(tt, 2)
+
+		for _, want := range []string{"Subject: Pending for " + alice, "Content-Type: text/html", "<p>0xtxa</p>"} {
+			if !strings.Contains(got[0].data, want) {
+				tt.Fatalf("message is missing '%s':\n%s", want, got[0].data)
+			}
+		}
+
+		if !strings.Contains(got[1].data, "Transaction 0xtxb") {
+			tt.Fatalf("expected the default template for mined events:\n%s", got[1].data)
+		}
+	})
+
+	t.Run("it batches the events of an address within the window", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{Window: 50 * time.Millisecond})
+
+		for _, hash := range []string{"0xtxa", "0xtxb", "0xtxc"} {
+			if err := notifier.Notify(testEvent(txnotify.EventMined, hash)); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		if got := server.received(); len(got) != 0 {
+			tt.Fatalf("got %d messages before the window ended, want none", len(got))
+		}
+
+		msg := server.waitFor(tt, 1)[0]
+
+		if !strings.Contains(msg.data, "3 mined notification(s)") {
+			tt.Fatalf("expected the 3 events in one message:\n%s", msg.data)
+		}
+
+		for _, hash := range []string{"0xtxa", "0xtxb", "0xtxc"} {
+			if !strings.Contains(msg.data, "Transaction "+hash) {
+				tt.Fatalf("message is missing %s:\n%s", hash, msg.data)
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if got := server.received(); len(got) != 1 {
+			tt.Fatalf("got %d messages, want 1", len(got))
+		}
+	})
+
+	t.Run("it sends failed batches again in the next window", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		server.failures = 1
+
+		notifier := setup(tt, server, Options{Window: 20 * time.Millisecond})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if msg := server.waitFor(tt, 1)[0]; !strings.Contains(msg.data, "Transaction 0xtxa") {
+			tt.Fatalf("expected the failed event to be sent again:\n%s", msg.data)
+		}
+	})
+
+	t.Run("it gives up on batches failing MaxAttempts times", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		server.failures = 10
+
+		failed := make(chan []txnotify.Event, 1)
+
+		notifier := setup(tt, server, Options{
+			Window:      10 * time.Millisecond,
+			MaxAttempts: 2,
+			OnError:     func(events []txnotify.Event, _ error) { failed <- events },
+		})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		select {
+		case events := <-failed:
+			if len(events) != 1 || events[0].Transactions[0].Hash != "0xtxa" {
+				tt.Fatalf("got %+v, want the failed event", events)
+			}
+		case <-time.After(2 * time.Second):
+			tt.Fatalf("timed out waiting for the batch to be given up on")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		server.mu.Lock()
+		left := server.failures
+		server.mu.Unlock()
+
+		if left != 8 {
+			tt.Fatalf("got %d attempts, want 2", 10-left)
+		}
+	})
+
+	t.Run("it sends pending batches on Close", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{Window: time.Hour})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if err := notifier.Close(); err != nil {
+			tt.Fatalf("could not close: %v", err)
+		}
+
+		if got := server.received(); len(got) != 1 {
+			tt.Fatalf("got %d messages, want 1", len(got))
+		}
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxb")); !errors.Is(err, txnotify.ErrNotifierClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, txnotify.ErrNotifierClosed)
+		}
+	})
+
+	t.Run("it sends pending batches on Flush", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{Window: time.Hour})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if err := notifier.Flush(); err != nil {
+			tt.Fatalf("could not flush: %v", err)
+		}
+
+		if got := server.received(); len(got) != 1 {
+			tt.Fatalf("got %d messages, want 1", len(got))
+		}
+	})
+
+	t.Run("it returns send errors without a window", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		server.failures = 1
+
+		notifier := setup(tt, server, Options{})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err == nil {
+			tt.Fatalf("expected the rejected message to return an error")
+		}
+	})
+
+	t.Run("it skips addresses without recipients", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{})
+
+		event := testEvent(txnotify.EventMined, "0xtxa")
+		event.Address = "0xb0b"
+
+		if err := notifier.Notify(event); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		if got := server.received(); len(got) != 0 {
+			tt.Fatalf("got %d messages, want none", len(got))
+		}
+	})
+
+	t.Run("it authenticates", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{Username: "user", Password: "pass"})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if msg := server.waitFor(tt, 1)[0]; msg.auth != "\x00user\x00pass" {
+			tt.Fatalf("got credentials %q, want user and pass", msg.auth)
+		}
+	})
+
+	t.Run("it upgrades the connection with STARTTLS", func(tt *testing.T) {
+		serverTLS, clientTLS := selfSigned(tt)
+
+		server := newSMTPServer(tt, serverTLS)
+		notifier := setup(tt, server, Options{StartTLS: true, TLSConfig: clientTLS})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if msg := server.waitFor(tt, 1)[0]; !msg.tls {
+			tt.Fatalf("expected the message to be sent over TLS")
+		}
+	})
+
+	t.Run("it fails when STARTTLS is not supported", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{StartTLS: true})
+
+		if err := notifier.Notify(testEvent(txnotify.EventMined, "0xtxa")); !errors.Is(err, ErrStartTLSUnsupported) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrStartTLSUnsupported)
+		}
+	})
+
+	t.Run("it rejects invalid recipients", func(tt *testing.T) {
+		server := newSMTPServer(tt, nil)
+		notifier := setup(tt, server, Options{})
+
+		if err := notifier.AddRecipients(alice, "not an email"); !errors.Is(err, ErrInvalidRecipient) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrInvalidRecipient)
+		}
+	})
+}
+
+func TestFuncs(t *testing.T) {
+	tmpl := template.Must(template.New("funcs").Funcs(Funcs).Parse(`{{ether .}}|{{decimal .}}`))
+
+	for hex, want := range map[string]string{
+		"0x0":                "0|0",
+		"0xde0b6b3a7640000":  "1|1000000000000000000",
+		"0x16345785d8a0000":  "0.1|100000000000000000",
+		"0x1":                "0.000000000000000001|1",
+		"0x1bc16d674ec80001": "2.000000000000000001|2000000000000000001",
+		"zz":                 "zz|zz",
+	} {
+		out := &strings.Builder{}
+		if err := tmpl.Execute(out, hex); err != nil {
+			t.Fatalf("could not execute: %v", err)
+		}
+
+		if out.String() != want {
+			t.Fatalf("(%s) got: '%s', want: '%s'", hex, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package email
+
+import (
+	"io"
+	"math/big"
+	"strings"
+	"text/template"
+
+	"github.com/aalbacetef/txnotify"
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// Template renders the "subject" and "body" templates of a message. Both text/template and
+// html/template templates implement it.
+type Template interface {
+	ExecuteTemplate(w io.Writer, name string, data any) error
+}
+
+// Message is the data templates are executed with: the events of one kind for one address
+// batched into a single email, with their contents merged in order.
+type Message struct {
+	Kind    txnotify.EventKind
+	Address string
+	Events  []txnotify.Event
+
+	Transactions   []ethereum.Transaction
+	TokenTransfers []ethereum.TokenTransfer
+	NFTTransfers   []ethereum.NFTTransfer
+}
+
+// Funcs are the functions available to the default templates. Add them to custom templates
+// with Funcs(email.Funcs) before parsing.
+var Funcs = map[string]any{
+	"decimal": decimal,
+	"ether":   ether,
+}
+
+const weiDecimals = 18
+
+// decimal formats a hex-string quantity in base 10, or returns it unchanged if it is not one.
+func decimal(hex string) string {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(hex, "0x"), 16)
+	if !ok {
+		return hex
+	}
+
+	return n.String()
+}
+
+// ether formats a hex-string amount of wei in ether, without trailing zeros.
+func ether(hex string) string {
+	wei, ok := new(big.Int).SetString(strings.TrimPrefix(hex, "0x"), 16)
+	if !ok {
+		return hex
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(weiDecimals), nil)
+	whole, frac := new(big.Int).QuoRem(wei, unit, new(big.Int))
+
+	if frac.Sign() == 0 {
+		return whole.String()
+	}
+
+	fraction := strings.TrimRight(strings.Repeat("0", weiDecimals-len(frac.String()))+frac.String(), "0")
+
+	return whole.String() + "." + fraction
+}
+
+const defaultTemplate = `
+{{- define "subject" -}}
+[txnotify] {{len .Events}} {{.Kind}} notification(s) for {{.Address}}
+{{- end -}}
+
+{{- define "body" -}}
+Address: {{.Address}}
+Event: {{.Kind}}
+{{range .Transactions}}
+Transaction {{.Hash}}
+  From:  {{.From}}
+  To:    {{if .To}}{{.To}}{{else}}contract creation{{end}}
+  Value: {{ether .Value}} ETH
+{{- if .BlockNumber}}
+  Block: {{decimal .BlockNumber}}
+{{- end}}
+{{- if .Receipt}}
+  Status: {{if .Receipt.Succeeded}}succeeded{{else}}failed{{end}}
+{{- end}}
+{{- if .ContractAddress}}
+  Contract: {{.ContractAddress}}
+{{- end}}
+{{- if .ReplacedBy}}
+  Replaced by: {{.ReplacedBy}}
+{{- end}}
+{{end}}
+{{- range .TokenTransfers}}
+Token transfer in {{.TransactionHash}}
+  Token:  {{.Token}}
+  From:   {{.From}}
+  To:     {{.To}}
+  Amount: {{decimal .Amount}}
+{{end}}
+{{- range .NFTTransfers}}
+{{.Standard}} transfer in {{.TransactionHash}}
+  Token:    {{.Token}} #{{decimal .TokenID}}
+  From:     {{.From}}
+  To:       {{.To}}
+{{end}}
+{{- end -}}
+`
+
+// DefaultTemplate is the plain text template used for the event kinds without a template.
+var DefaultTemplate = template.Must(template.New("txnotify").Funcs(Funcs).Parse(defaultTemplate))
@@ -3,5 +3,20 @@ package ethereum
 // @NOTE: we only care about a few fields.
 type Block struct {
 	Hash         string        `json:"hash"`
+	Number       string        `json:"number"`
+	ParentHash   string        `json:"parentHash"`
+	Timestamp    string        `json:"timestamp"`
 	Transactions []Transaction `json:"transactions"`
+
+	// TokenTransfers and NFTTransfers are not part of the JSON-RPC block object, they are
+	// decoded by the Watcher from the block's logs.
+	TokenTransfers []TokenTransfer `json:"tokenTransfers,omitempty"`
+	NFTTransfers   []NFTTransfer   `json:"nftTransfers,omitempty"`
+}
+
+// Header is the subset of block fields delivered by newHeads subscriptions.
+type Header struct {
+	Hash       string `json:"hash"`
+	Number     string `json:"number"`
+	ParentHash string `json:"parentHash"`
 }
@@ -0,0 +1,51 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	addressLen = 20
+
+	// rlpString and rlpList are the RLP prefixes of short (under 56 bytes) strings and lists.
+	rlpString = 0x80
+	rlpList   = 0xc0
+)
+
+// ContractAddress returns the address of the contract created by sender with the given nonce
+// (a hex-string, as in Transaction.Nonce): the last 20 bytes of keccak256(rlp([sender, nonce])).
+// It is the address a contract creation transaction deploys to, and the one a contract's n-th
+// CREATE deploys to, where the contract's nonce starts at 1.
+func ContractAddress(sender, nonce string) (string, error) {
+	addr, err := hex.DecodeString(fmt.Sprintf("%040s", strings.TrimPrefix(strings.ToLower(sender), "0x")))
+	if err != nil || len(addr) != addressLen {
+		return "", fmt.Errorf("invalid sender address '%s'", sender)
+	}
+
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(nonce, "0x"), 16)
+	if !ok || n.Sign() < 0 {
+		return "", fmt.Errorf("invalid nonce '%s'", nonce)
+	}
+
+	payload := append([]byte{rlpString + addressLen}, addr...)
+	payload = append(payload, rlpUint(n.Bytes())...)
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(append([]byte{rlpList + byte(len(payload))}, payload...))
+
+	return "0x" + hex.EncodeToString(hash.Sum(nil)[32-addressLen:]), nil
+}
+
+// rlpUint encodes the big-endian bytes (without leading zeros) of an integer.
+func rlpUint(b []byte) []byte {
+	if len(b) == 1 && b[0] < rlpString {
+		return b
+	}
+
+	return append([]byte{rlpString + byte(len(b))}, b...)
+}
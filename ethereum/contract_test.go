@@ -0,0 +1,27 @@
+package ethereum
+
+import (
+	"testing"
+)
+
+func TestContractAddress(t *testing.T) {
+	const deployer = "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0"
+
+	want := map[string]string{
+		"0x0": "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+		"0x1": "0x343c43a37d37dff08ae8c4a11544c718abb4fcf8",
+		"0x2": "0xf778b86fa74e846c4f0a1fbd1335fe81c00a0c91",
+		"0x3": "0xfffd933a0bc612844eaf0c6fe3e5b8e9b6c1d19c",
+	}
+
+	for nonce, addr := range want {
+		got, err := ContractAddress(deployer, nonce)
+		if err != nil {
+			t.Fatalf("(nonce %s) could not compute address: %v", nonce, err)
+		}
+
+		if got != addr {
+			t.Fatalf("(nonce %s) got %s, want %s", nonce, got, addr)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package ethereum
+
+import (
+	"math/big"
+	"strings"
+)
+
+const (
+	// TransferSingleEventTopic is the signature hash of the ERC-1155 event
+	// TransferSingle(address,address,address,uint256,uint256).
+	TransferSingleEventTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+
+	// TransferBatchEventTopic is the signature hash of the ERC-1155 event
+	// TransferBatch(address,address,address,uint256[],uint256[]).
+	TransferBatchEventTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+)
+
+// NFTStandard identifies the token standard an NFT transfer was decoded from.
+type NFTStandard string
+
+const (
+	ERC721  NFTStandard = "erc721"
+	ERC1155 NFTStandard = "erc1155"
+)
+
+// NFTTransfer is an ERC-721 or ERC-1155 transfer decoded from an event log.
+// A TransferBatch event is decoded into one NFTTransfer per token ID.
+type NFTTransfer struct {
+	Standard NFTStandard `json:"standard"`
+
+	// Token is the address of the token contract that emitted the event.
+	Token string `json:"token"`
+
+	// Operator is the address that performed the transfer (ERC-1155 only).
+	Operator string `json:"operator,omitempty"`
+
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// TokenID and Amount are encoded as hexadecimal strings. Amount is always 0x1 for ERC-721.
+	TokenID string `json:"tokenId"`
+	Amount  string `json:"amount"`
+
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     string `json:"blockNumber"`
+	LogIndex        string `json:"logIndex"`
+}
+
+// DecodeNFTTransfers decodes ERC-721 Transfer and ERC-1155 TransferSingle/TransferBatch
+// events, reporting false if the log is none of them.
+func DecodeNFTTransfers(log Log) ([]NFTTransfer, bool) {
+	const (
+		erc721TopicCount  = 4
+		erc1155TopicCount = 4
+	)
+
+	if len(log.Topics) == 0 {
+		return nil, false
+	}
+
+	base := NFTTransfer{
+		Token:           strings.ToLower(log.Address),
+		TransactionHash: log.TransactionHash,
+		BlockNumber:     log.BlockNumber,
+		LogIndex:        log.LogIndex,
+	}
+
+	switch topic := strings.ToLower(log.Topics[0]); {
+	case topic == TransferEventTopic && len(log.Topics) == erc721TopicCount:
+		tokenID, ok := decodeWord(log.Topics[3])
+		if !ok {
+			return nil, false
+		}
+
+		base.Standard = ERC721
+		base.From = topicToAddress(log.Topics[1])
+		base.To = topicToAddress(log.Topics[2])
+		base.TokenID = tokenID
+		base.Amount = "0x1"
+
+		return []NFTTransfer{base}, true
+
+	case topic == TransferSingleEventTopic && len(log.Topics) == erc1155TopicCount:
+		words, ok := splitWords(log.Data)
+		if !ok || len(words) != 2 {
+			return nil, false
+		}
+
+		base.Standard = ERC1155
+		base.Operator = topicToAddress(log.Topics[1])
+		base.From = topicToAddress(log.Topics[2])
+		base.To = topicToAddress(log.Topics[3])
+		base.TokenID = wordToQuantity(words[0])
+		base.Amount = wordToQuantity(words[1])
+
+		return []NFTTransfer{base}, true
+
+	case topic == TransferBatchEventTopic && len(log.Topics) == erc1155TopicCount:
+		return decodeTransferBatch(base, log)
+	}
+
+	return nil, false
+}
+
+// decodeTransferBatch decodes the (uint256[] ids, uint256[] values) data of a TransferBatch event.
+func decodeTransferBatch(base NFTTransfer, log Log) ([]NFTTransfer, bool) {
+	words, ok := splitWords(log.Data)
+	if !ok || len(words) < 2 {
+		return nil, false
+	}
+
+	ids, ok := wordArray(words, words[0])
+	if !ok {
+		return nil, false
+	}
+
+	values, ok := wordArray(words, words[1])
+	if !ok || len(values) != len(ids) {
+		return nil, false
+	}
+
+	base.Standard = ERC1155
+	base.Operator = topicToAddress(log.Topics[1])
+	base.From = topicToAddress(log.Topics[2])
+	base.To = topicToAddress(log.Topics[3])
+
+	transfers := make([]NFTTransfer, len(ids))
+
+	for i := range ids {
+		transfers[i] = base
+		transfers[i].TokenID = wordToQuantity(ids[i])
+		transfers[i].Amount = wordToQuantity(values[i])
+	}
+
+	return transfers, true
+}
+
+// wordArray reads the dynamic uint256[] whose byte offset is stored in offsetWord.
+// Offsets and lengths come from untrusted log data, so they are bounds-checked
+// against the number of words before any arithmetic that could overflow.
+func wordArray(words []*big.Int, offsetWord *big.Int) ([]*big.Int, bool) {
+	const wordSize = 32
+
+	if !offsetWord.IsInt64() || offsetWord.Int64()%wordSize != 0 {
+		return nil, false
+	}
+
+	offset := offsetWord.Int64() / wordSize
+	if offset < 0 || offset >= int64(len(words)) {
+		return nil, false
+	}
+
+	start := int(offset)
+	if !words[start].IsInt64() {
+		return nil, false
+	}
+
+	length := words[start].Int64()
+	if length < 0 || length > int64(len(words)-start-1) {
+		return nil, false
+	}
+
+	return words[start+1 : start+1+int(length)], true
+}
+
+// splitWords splits ABI-encoded data into its 32-byte words.
+func splitWords(data string) ([]*big.Int, bool) {
+	const wordHexLen = 64
+
+	hex := strings.TrimPrefix(data, "0x")
+	if len(hex)%wordHexLen != 0 {
+		return nil, false
+	}
+
+	words := make([]*big.Int, 0, len(hex)/wordHexLen)
+
+	for i := 0; i < len(hex); i += wordHexLen {
+		word, ok := new(big.Int).SetString(hex[i:i+wordHexLen], 16)
+		if !ok {
+			return nil, false
+		}
+
+		words = append(words, word)
+	}
+
+	return words, true
+}
+
+func wordToQuantity(word *big.Int) string {
+	return "0x" + word.Text(16)
+}
@@ -0,0 +1,58 @@
+package ethereum
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeMalformedTransferBatch(t *testing.T) {
+	word := func(v string) string {
+		return strings.Repeat("0", 64-len(v)) + v
+	}
+
+	topics := []string{TransferBatchEventTopic, "0x" + word("ca201"), "0x" + word("ca201"), "0x" + word("a11ce")}
+
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"huge length", word("40") + word("40") + word("7fffffffffffffff")},
+		{"length past end", word("40") + word("40") + word("3") + word("1")},
+		{"offset past end", word("40") + word("2000")},
+		{"negative offset", word("40") + strings.Repeat("f", 64)},
+		{"unaligned offset", word("41") + word("40") + word("0")},
+		{"mismatched lengths", word("40") + word("80") + word("1") + word("1") + word("2") + word("1") + word("1")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			log := Log{Address: "0xitems", Topics: topics, Data: "0x" + c.data}
+
+			transfers, ok := DecodeNFTTransfers(log)
+			if ok {
+				t.Fatalf("expected malformed log to be rejected, got %+v", transfers)
+			}
+		})
+	}
+}
+
+func TestDecodeTransferBatch(t *testing.T) {
+	word := func(v string) string {
+		return strings.Repeat("0", 64-len(v)) + v
+	}
+
+	log := Log{
+		Address: "0xitems",
+		Topics:  []string{TransferBatchEventTopic, "0x" + word("ca201"), "0x" + word("ca201"), "0x" + word("a11ce")},
+		Data:    "0x" + word("40") + word("80") + word("1") + word("7") + word("1") + word("3"),
+	}
+
+	transfers, ok := DecodeNFTTransfers(log)
+	if !ok || len(transfers) != 1 {
+		t.Fatalf("expected one transfer, got %+v (ok=%t)", transfers, ok)
+	}
+
+	if transfers[0].TokenID != "0x7" || transfers[0].Amount != "0x3" {
+		t.Fatalf("unexpected transfer: %+v", transfers[0])
+	}
+}
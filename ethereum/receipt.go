@@ -0,0 +1,63 @@
+package ethereum
+
+const (
+	// ReceiptStatusSuccess is the receipt status of a transaction that executed successfully.
+	ReceiptStatusSuccess = "0x1"
+
+	// ReceiptStatusFailure is the receipt status of a transaction that reverted.
+	ReceiptStatusFailure = "0x0"
+)
+
+// Receipt represents a JSON-RPC transaction receipt object as per the Ethereum JSON-RPC specification.
+type Receipt struct {
+	// TransactionHash is the 32-byte hash of the transaction.
+	TransactionHash  string `json:"transactionHash"`
+	TransactionIndex string `json:"transactionIndex"`
+	BlockHash        string `json:"blockHash"`
+	BlockNumber      string `json:"blockNumber"`
+
+	From string  `json:"from"`
+	To   *string `json:"to,omitempty"`
+
+	// Status is either "0x1" (success) or "0x0" (failure).
+	Status string `json:"status"`
+
+	// GasUsed is the amount of gas used by this transaction alone.
+	GasUsed           string `json:"gasUsed"`
+	CumulativeGasUsed string `json:"cumulativeGasUsed"`
+
+	// EffectiveGasPrice is the price per gas actually paid by the sender.
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+
+	// ContractAddress is the address of the created contract, if the transaction was a contract creation.
+	ContractAddress *string `json:"contractAddress,omitempty"`
+
+	Logs []Log  `json:"logs"`
+	Type string `json:"type"`
+}
+
+// Succeeded reports whether the transaction executed successfully.
+func (receipt Receipt) Succeeded() bool {
+	return receipt.Status == ReceiptStatusSuccess
+}
+
+// Log represents an event emitted by a contract during a transaction's execution.
+type Log struct {
+	// Address is the 20-byte address of the contract that emitted the log.
+	Address string `json:"address"`
+
+	// Topics holds the indexed event arguments, the first being the event signature hash.
+	Topics []string `json:"topics"`
+
+	// Data holds the non-indexed event arguments, ABI-encoded.
+	Data string `json:"data"`
+
+	BlockNumber      string `json:"blockNumber"`
+	BlockHash        string `json:"blockHash"`
+	TransactionHash  string `json:"transactionHash"`
+	TransactionIndex string `json:"transactionIndex"`
+	LogIndex         string `json:"logIndex"`
+
+	// Removed is true when the log was dropped by a chain reorganization.
+	Removed bool `json:"removed"`
+}
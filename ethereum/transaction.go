@@ -50,6 +50,18 @@ type Transaction struct {
 	Value string `json:"value"`
 
 	YParity *string `json:"yParity,omitempty"`
+
+	// Receipt is not part of the JSON-RPC transaction object, it is attached by the
+	// Watcher once the transaction is mined so notifications carry its execution status.
+	Receipt *Receipt `json:"receipt,omitempty"`
+
+	// ReplacedBy is not part of the JSON-RPC transaction object either. It is set by the Watcher
+	// on a pending transaction that was replaced, to the hash of the transaction mined in its place.
+	ReplacedBy *string `json:"replacedBy,omitempty"`
+
+	// ContractAddress is set by the Watcher on mined contract creations, to the address of the
+	// deployed contract: the receipt's contractAddress, or computed from the sender and nonce.
+	ContractAddress *string `json:"contractAddress,omitempty"`
 }
 
 // AccessListEntry represents an entry in the access list for access list transactions (EIP-2930).
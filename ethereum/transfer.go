@@ -0,0 +1,68 @@
+package ethereum
+
+import "strings"
+
+// TransferEventTopic is the signature hash of the ERC-20 event Transfer(address,address,uint256).
+const TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// TokenTransfer is an ERC-20 transfer decoded from a Transfer event log.
+type TokenTransfer struct {
+	// Token is the address of the token contract that emitted the event.
+	Token string `json:"token"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+
+	// Amount is the raw amount transferred (not scaled by the token's decimals), encoded as a hexadecimal string.
+	Amount string `json:"amount"`
+
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     string `json:"blockNumber"`
+	LogIndex        string `json:"logIndex"`
+}
+
+// DecodeTokenTransfer decodes an ERC-20 Transfer event, reporting false if the log is not one.
+// ERC-721 transfers share the event signature but index the token ID, so they are rejected.
+func DecodeTokenTransfer(log Log) (TokenTransfer, bool) {
+	const erc20TopicCount = 3
+
+	if len(log.Topics) != erc20TopicCount || !strings.EqualFold(log.Topics[0], TransferEventTopic) {
+		return TokenTransfer{}, false
+	}
+
+	amount, ok := decodeWord(log.Data)
+	if !ok {
+		return TokenTransfer{}, false
+	}
+
+	return TokenTransfer{
+		Token:           strings.ToLower(log.Address),
+		From:            topicToAddress(log.Topics[1]),
+		To:              topicToAddress(log.Topics[2]),
+		Amount:          amount,
+		TransactionHash: log.TransactionHash,
+		BlockNumber:     log.BlockNumber,
+		LogIndex:        log.LogIndex,
+	}, true
+}
+
+// topicToAddress extracts the address stored in the low 20 bytes of a 32-byte topic.
+func topicToAddress(topic string) string {
+	const addressHexLen = 40
+
+	hex := strings.TrimPrefix(topic, "0x")
+	if len(hex) > addressHexLen {
+		hex = hex[len(hex)-addressHexLen:]
+	}
+
+	return "0x" + strings.ToLower(hex)
+}
+
+// decodeWord decodes a single ABI-encoded uint256 as a hexadecimal quantity.
+func decodeWord(data string) (string, bool) {
+	words, ok := splitWords(data)
+	if !ok || len(words) != 1 {
+		return "", false
+	}
+
+	return wordToQuantity(words[0]), true
+}
@@ -0,0 +1,157 @@
+package txnotify
+
+import (
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// EventVersion is the version of the Event format, bumped on incompatible changes.
+const EventVersion = 1
+
+// EventKind describes why a notification is being sent.
+type EventKind string
+
+const (
+	// EventMined is sent when transactions are included in a processed block with
+	// the configured number of confirmations.
+	EventMined EventKind = "mined"
+
+	// EventUnconfirmed is sent when transactions are included in a processed block that
+	// does not have the configured number of confirmations yet.
+	EventUnconfirmed EventKind = "unconfirmed"
+
+	// EventReverted is sent when previously notified transactions were dropped
+	// from the canonical chain by a reorganization.
+	EventReverted EventKind = "reverted"
+
+	// EventPending is sent when a transaction enters the mempool, ahead of its mined notification.
+	EventPending EventKind = "pending"
+
+	// EventReplaced is sent when a pending transaction's sender and nonce are used by a different
+	// mined transaction, as when it is sped up or cancelled. The transaction's ReplacedBy field
+	// holds the hash of the mined one.
+	EventReplaced EventKind = "replaced"
+
+	// EventDropped is sent when the node no longer knows a pending transaction, and has not for DropAfter.
+	EventDropped EventKind = "dropped"
+
+	// EventDeployed is sent when a subscribed address deploys a contract, once the creation
+	// is mined. The transaction's ContractAddress holds the new contract's address.
+	EventDeployed EventKind = "deployed"
+)
+
+// Event is a notification sent to a Notifier about one subscribed address.
+type Event struct {
+	Version int       `json:"version"`
+	Kind    EventKind `json:"kind"`
+
+	// ChainID is the id of the watched chain as a hex-string, empty if the node did not report it.
+	ChainID string `json:"chainId,omitempty"`
+
+	// Address is the (normalized) subscribed address the event is about.
+	Address string `json:"address"`
+
+	// BlockNumber, BlockHash and BlockTimestamp (hex-strings) describe the block the contents were
+	// included in. They are empty for events about transactions that are not in a block, such as
+	// pending, replaced, dropped and reverted ones.
+	BlockNumber    string `json:"blockNumber,omitempty"`
+	BlockHash      string `json:"blockHash,omitempty"`
+	BlockTimestamp string `json:"blockTimestamp,omitempty"`
+
+	// Confirmations is the number of blocks built on top of the block when the event was sent.
+	Confirmations int `json:"confirmations"`
+
+	// Sequence numbers the events of a Watcher in the order they were produced, starting at 1.
+	// The events of an address are delivered in order, but those of different addresses are
+	// delivered concurrently. Gaps mean events were dropped, see Config.DropPolicy.
+	Sequence uint64 `json:"sequence"`
+
+	// SentAt is when the event was produced.
+	SentAt time.Time `json:"sentAt"`
+
+	Transactions   []ethereum.Transaction   `json:"transactions,omitempty"`
+	TokenTransfers []ethereum.TokenTransfer `json:"tokenTransfers,omitempty"`
+	NFTTransfers   []ethereum.NFTTransfer   `json:"nftTransfers,omitempty"`
+
+	// delivery is the block the event is counted against until it is delivered, if any.
+	delivery *blockDelivery
+}
+
+// Empty reports whether the event carries no transactions or transfers.
+func (event Event) Empty() bool {
+	return len(event.Transactions) == 0 && len(event.TokenTransfers) == 0 && len(event.NFTTransfers) == 0
+}
+
+// blockEvent returns an event about the contents of block, which may be empty for contents
+// that are no longer in a block.
+func (watcher *Watcher) blockEvent(kind EventKind, address string, block ethereum.Block) Event {
+	event := Event{
+		Kind:           kind,
+		Address:        address,
+		BlockNumber:    block.Number,
+		BlockHash:      block.Hash,
+		BlockTimestamp: block.Timestamp,
+	}
+
+	if block.Number == "" {
+		return event
+	}
+
+	latest, err := strToHex(watcher.copyState().latestBlock)
+	if err != nil {
+		return event
+	}
+
+	if num, err := strToHex(block.Number); err == nil && latest > num {
+		event.Confirmations = latest - num
+	}
+
+	return event
+}
+
+// notify stamps an event with the envelope fields and queues it for delivery, unless it is empty.
+func (watcher *Watcher) notify(event Event) {
+	if event.Empty() {
+		return
+	}
+
+	watcher.mu.Lock()
+	event.ChainID = watcher.chainID
+
+	if watcher.dispatcher == nil {
+		watcher.dispatcher = newDispatcher(
+			watcher.workers,
+			watcher.queueSize,
+			watcher.dropPolicy,
+			watcher.deliver,
+			watcher.dropEvent,
+		)
+	}
+
+	dispatcher := watcher.dispatcher
+	watcher.mu.Unlock()
+
+	event.Version = EventVersion
+
+	watcher.trackDelivery(event.delivery)
+	dispatcher.enqueue(event)
+}
+
+// loadChainID asks the node for the chain id, if the client supports it.
+func (watcher *Watcher) loadChainID() {
+	client, ok := watcher.rpcClient.(ChainIDClient)
+	if !ok {
+		return
+	}
+
+	resp, err := client.ChainID()
+	if err != nil {
+		watcher.logger.Warn("could not get chain id", "error", err)
+		return
+	}
+
+	watcher.mu.Lock()
+	watcher.chainID = resp.Result
+	watcher.mu.Unlock()
+}
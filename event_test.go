@@ -0,0 +1,114 @@
+package txnotify
+
+import (
+	"testing"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func TestWatcherEvents(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+		carol = "0xca201"
+	)
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.setHead("0x10")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.loadChainID()
+
+	for _, addr := range []string{alice, bob} {
+		if err := watcher.Subscribe(Subscription{Address: addr}); err != nil {
+			t.Fatalf("could not subscribe: %v", err)
+		}
+	}
+
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	chain.addBlock("0x11", "0xh11", "0xh10", ethereum.Transaction{Hash: "0xtxa", From: alice, To: ptr(carol)})
+	chain.mu.Lock()
+	block := chain.blocks["0x11"]
+	block.Timestamp = "0x6553f100"
+	chain.blocks["0x11"] = block
+	chain.mu.Unlock()
+
+	chain.addBlock("0x12", "0xh12", "0xh11", ethereum.Transaction{Hash: "0xtxb", From: carol, To: ptr(alice)})
+	chain.setHead("0x12")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventMined, alice, "0xtxb")
+
+	t.Run("it sets the envelope of block events", func(tt *testing.T) {
+		event, ok := notifier.event(EventMined, alice, "0xtxa")
+		if !ok {
+			tt.Fatalf("no event for 0xtxa")
+		}
+
+		want := Event{
+			Version:        EventVersion,
+			Kind:           EventMined,
+			ChainID:        "0x1",
+			Address:        alice,
+			BlockNumber:    "0x11",
+			BlockHash:      "0xh11",
+			BlockTimestamp: "0x6553f100",
+			Confirmations:  1,
+		}
+
+		got := event
+
+		if got.Version != want.Version || got.Kind != want.Kind || got.ChainID != want.ChainID ||
+			got.Address != want.Address || got.BlockNumber != want.BlockNumber || got.BlockHash != want.BlockHash ||
+			got.BlockTimestamp != want.BlockTimestamp || got.Confirmations != want.Confirmations {
+			tt.Fatalf("got %+v, want %+v", got, want)
+		}
+
+		if event.SentAt.IsZero() {
+			tt.Fatalf("SentAt should be set")
+		}
+	})
+
+	t.Run("it numbers events in the order they are produced", func(tt *testing.T) {
+		first, _ := notifier.event(EventMined, alice, "0xtxa")
+		second, _ := notifier.event(EventMined, alice, "0xtxb")
+
+		if first.Sequence == 0 || second.Sequence <= first.Sequence {
+			tt.Fatalf("got sequences %d and %d, want them increasing from 1", first.Sequence, second.Sequence)
+		}
+	})
+
+	t.Run("it delivers the events of an address in order", func(tt *testing.T) {
+		var last uint64
+
+		for _, event := range notifier.all() {
+			if event.Address != alice {
+				continue
+			}
+
+			if event.Sequence <= last {
+				tt.Fatalf("got sequence %d after %d", event.Sequence, last)
+			}
+
+			last = event.Sequence
+		}
+	})
+
+	t.Run("it suppresses empty notifications", func(tt *testing.T) {
+		for _, event := range notifier.all() {
+			if event.Empty() {
+				tt.Fatalf("got empty event %+v", event)
+			}
+
+			if event.Address == bob {
+				tt.Fatalf("addresses without transactions should not be notified, got %+v", event)
+			}
+		}
+	})
+}
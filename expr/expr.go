@@ -0,0 +1,265 @@
+// Package expr implements a small filter language over Ethereum transactions, such as:
+//
+//	to == 0xdac17f958d2ee523a2206206994597c13d831ec7 && input.selector == 0xa9059cbb
+//	value > 1e18 || (from in (0xabc, 0xdef) && !(gasPrice < 20e9))
+//
+// Every field is a number: addresses, hashes and selectors are compared by their hex value, so
+// case and leading zeros do not matter. Numbers are written in hex (0x...), decimal, or scientific
+// notation, and are unsigned integers of at most 256 bits: there is no unary minus and no
+// arithmetic, so 1-2 is a syntax error. Fields a transaction does not have, like the recipient of
+// a contract creation, are null: they only equal null, and ordered comparisons involving them are
+// false.
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// MaxLength is the longest expression, in bytes, Compile accepts.
+const MaxLength = 4096
+
+// selectorLen is the length of a hex-encoded 4-byte method selector, including its 0x prefix.
+const selectorLen = 10
+
+// SyntaxError reports an expression that could not be compiled.
+type SyntaxError struct {
+	// Pos is the byte offset in the expression the error was found at.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Program is a compiled expression. It is safe for concurrent use.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses an expression.
+func Compile(src string) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("expression is longer than %d bytes", MaxLength)}
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokens[0].kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty expression"}
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok)}
+	}
+
+	return &Program{src: src, root: root}, nil
+}
+
+// Match reports whether the transaction satisfies the expression.
+func (prog *Program) Match(tx ethereum.Transaction) bool {
+	return prog.root.eval(&env{tx: tx})
+}
+
+func (prog *Program) String() string {
+	return prog.src
+}
+
+// Fields returns the names of the fields expressions can refer to, sorted.
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// fields maps field names to their hex-encoded value in a transaction. Fields
+// reported as missing are null.
+var fields = map[string]func(tx ethereum.Transaction) (string, bool){
+	"hash":                 func(tx ethereum.Transaction) (string, bool) { return tx.Hash, tx.Hash != "" },
+	"from":                 func(tx ethereum.Transaction) (string, bool) { return tx.From, tx.From != "" },
+	"to":                   func(tx ethereum.Transaction) (string, bool) { return deref(tx.To) },
+	"value":                func(tx ethereum.Transaction) (string, bool) { return tx.Value, tx.Value != "" },
+	"nonce":                func(tx ethereum.Transaction) (string, bool) { return tx.Nonce, tx.Nonce != "" },
+	"gas":                  func(tx ethereum.Transaction) (string, bool) { return tx.Gas, tx.Gas != "" },
+	"gasPrice":             func(tx ethereum.Transaction) (string, bool) { return tx.GasPrice, tx.GasPrice != "" },
+	"maxFeePerGas":         func(tx ethereum.Transaction) (string, bool) { return deref(tx.MaxFeePerGas) },
+	"maxPriorityFeePerGas": func(tx ethereum.Transaction) (string, bool) { return deref(tx.MaxPriorityFeePerGas) },
+	"type":                 func(tx ethereum.Transaction) (string, bool) { return tx.Type, tx.Type != "" },
+	"chainId":              func(tx ethereum.Transaction) (string, bool) { return deref(tx.ChainID) },
+	"blockNumber":          func(tx ethereum.Transaction) (string, bool) { return deref(tx.BlockNumber) },
+	"input":                func(tx ethereum.Transaction) (string, bool) { return tx.Input, tx.Input != "" },
+	"input.selector": func(tx ethereum.Transaction) (string, bool) {
+		if len(tx.Input) < selectorLen {
+			return "", false
+		}
+
+		return tx.Input[:selectorLen], true
+	},
+	"input.length": func(tx ethereum.Transaction) (string, bool) {
+		return fmt.Sprintf("%#x", len(strings.TrimPrefix(tx.Input, "0x"))/2), true
+	},
+	"status": func(tx ethereum.Transaction) (string, bool) {
+		if tx.Receipt == nil {
+			return "", false
+		}
+
+		return tx.Receipt.Status, tx.Receipt.Status != ""
+	},
+}
+
+func deref(s *string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+
+	return *s, true
+}
+
+// env evaluates fields of a transaction.
+type env struct {
+	tx ethereum.Transaction
+}
+
+// lookup returns the value of a field, or nil if it is null or not a valid number.
+func (e *env) lookup(name string) *big.Int {
+	raw, ok := fields[name](e.tx)
+	if !ok {
+		return nil
+	}
+
+	value, err := parseNumber(raw)
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+type node interface {
+	eval(e *env) bool
+}
+
+type operand interface {
+	value(e *env) *big.Int
+}
+
+type field struct {
+	name string
+}
+
+func (f field) value(e *env) *big.Int {
+	return e.lookup(f.name)
+}
+
+// literal is a number, or null if v is nil.
+type literal struct {
+	v *big.Int
+}
+
+func (l literal) value(_ *env) *big.Int {
+	return l.v
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(e *env) bool {
+	return n.left.eval(e) || n.right.eval(e)
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(e *env) bool {
+	return n.left.eval(e) && n.right.eval(e)
+}
+
+type notNode struct {
+	inner node
+}
+
+func (n notNode) eval(e *env) bool {
+	return !n.inner.eval(e)
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+func (n compareNode) eval(e *env) bool {
+	left, right := n.left.value(e), n.right.value(e)
+
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	if left == nil || right == nil {
+		return false
+	}
+
+	cmp := left.Cmp(right)
+
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type inNode struct {
+	left operand
+	list []operand
+}
+
+func (n inNode) eval(e *env) bool {
+	left := n.left.value(e)
+
+	for _, item := range n.list {
+		if equal(left, item.value(e)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equal compares two values, where null only equals null.
+func equal(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return a.Cmp(b) == 0
+}
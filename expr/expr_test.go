@@ -0,0 +1,111 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestMatch(t *testing.T) {
+	transfer := ethereum.Transaction{
+		Hash:     "0xabc123",
+		From:     "0x00000000000000000000000000000000000A11CE",
+		To:       ptr("0xdac17f958d2ee523a2206206994597c13d831ec7"),
+		Value:    "0x1bc16d674ec80000", // 2 ether
+		GasPrice: "0x4a817c800",        // 20 gwei
+		Nonce:    "0x7",
+		Input:    "0xa9059cbb000000000000000000000000000000000000000000000000000000000000b0b0",
+		Receipt:  &ethereum.Receipt{Status: "0x1"},
+	}
+
+	creation := ethereum.Transaction{From: "0xa11ce", Value: "0x0", Input: "0x6080"}
+
+	testCases := []struct {
+		src  string
+		tx   ethereum.Transaction
+		want bool
+	}{
+		{"to == 0xdAC17F958D2ee523a2206206994597C13D831ec7", transfer, true},
+		{"from == 0xa11ce", transfer, true},
+		{"value > 1e18", transfer, true},
+		{"value >= 2e18 && value <= 2000000000000000000", transfer, true},
+		{"value > 1.5e18 && value < 3e18", transfer, true},
+		{"value > 2e18", transfer, false},
+		{"input.selector == 0xa9059cbb", transfer, true},
+		{"input.selector in (0x23b872dd, 0xa9059cbb)", transfer, true},
+		{"input.length == 36", transfer, true},
+		{"input.length == 68", transfer, false},
+		{"!(gasPrice < 20e9) && nonce == 7", transfer, true},
+		{"status == 1", transfer, true},
+		{"to == 0xdac && value > 1e18 || from == 0xa11ce", transfer, true},
+		{"to == 0xdac && (value > 1e18 || from == 0xa11ce)", transfer, false},
+		{"to == null", creation, true},
+		{"to != 0xdac17f958d2ee523a2206206994597c13d831ec7", creation, true},
+		{"to > 0", creation, false},
+		{"input.selector == null", creation, true},
+		{"status == null", creation, true},
+		{"value < 1e+19", transfer, true},
+		{strings.Repeat("!", maxDepth-1) + "(value > 1)", transfer, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run("it evaluates "+tc.src, func(tt *testing.T) {
+			prog, err := Compile(tc.src)
+			if err != nil {
+				tt.Fatalf("could not compile: %v", err)
+			}
+
+			if got := prog.Match(tc.tx); got != tc.want {
+				tt.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	testCases := []struct {
+		src string
+		pos int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"recipient == 0x1", 0},
+		{"value >", 7},
+		{"value > 1 &&", 12},
+		{"value = 1", 6},
+		{"(value > 1", 10},
+		{"value > 1.5", 8},
+		{"value > 0xzz", 8},
+		{"to in 0x1", 6},
+		{"value > 1 value", 10},
+		{"to == 0x1 # comment", 10},
+		{"value > 1-2", 9},
+		{"value > -1", 8},
+		{"value > 1e300000000", 8},
+		{"value > 0x1" + strings.Repeat("0", 64), 8},
+		{strings.Repeat("!", maxDepth) + "!(value > 1)", maxDepth},
+		{strings.Repeat("(", maxDepth+1) + "value > 1" + strings.Repeat(")", maxDepth+1), maxDepth},
+		{strings.Repeat("!", MaxLength) + "value > 1", MaxLength},
+	}
+
+	for _, tc := range testCases {
+		t.Run("it rejects '"+tc.src+"'", func(tt *testing.T) {
+			_, err := Compile(tc.src)
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				tt.Fatalf("got: '%v', want a *SyntaxError", err)
+			}
+
+			if syntaxErr.Pos != tc.pos {
+				tt.Fatalf("got position %d, want %d (%v)", syntaxErr.Pos, tc.pos, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (tok token) String() string {
+	if tok.kind == tokenEOF {
+		return "end of expression"
+	}
+
+	return fmt.Sprintf("'%s'", tok.text)
+}
+
+// operators lists the multi- and single-character operators, longest first.
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+// lex splits an expression into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(src); {
+		c := rune(src[pos])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			pos++
+
+		case isDigit(c):
+			end := scanNumber(src, pos)
+			tokens = append(tokens, token{kind: tokenNumber, text: src[pos:end], pos: pos})
+			pos = end
+
+		case c == '-' || c == '+':
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected '%c': arithmetic and negative numbers are not supported", c)}
+
+		case unicode.IsLetter(c) || c == '_':
+			end := scan(src, pos, func(r rune) bool {
+				return unicode.IsLetter(r) || isDigit(r) || r == '_' || r == '.'
+			})
+			tokens = append(tokens, token{kind: tokenIdent, text: src[pos:end], pos: pos})
+			pos = end
+
+		default:
+			op := matchOperator(src[pos:])
+			if op == "" {
+				return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character '%c'", c)}
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+			pos += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+func scan(src string, pos int, accept func(rune) bool) int {
+	end := pos
+	for end < len(src) && accept(rune(src[end])) {
+		end++
+	}
+
+	return end
+}
+
+// scanNumber returns the end of the number starting at pos. A sign is only part of a number as
+// the exponent sign of a decimal, as in 1e+18, so that 1-2 is not read as a single number.
+func scanNumber(src string, pos int) int {
+	hex := strings.HasPrefix(strings.ToLower(src[pos:]), "0x")
+
+	end := pos
+	for end < len(src) {
+		r := rune(src[end])
+
+		isSign := (r == '+' || r == '-') && !hex && end > pos && (src[end-1] == 'e' || src[end-1] == 'E')
+		if !isDigit(r) && !unicode.IsLetter(r) && r != '.' && !isSign {
+			break
+		}
+
+		end++
+	}
+
+	return end
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
@@ -0,0 +1,296 @@
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// precision is the mantissa precision used to parse scientific notation, enough for 256-bit values.
+	precision = 512
+
+	// maxBits is the widest number an expression may contain, matching the EVM's 256-bit words.
+	maxBits = 256
+
+	// maxDepth bounds how deeply "!" and parentheses may nest, so a hostile expression cannot
+	// exhaust the stack of the recursive descent parser.
+	maxDepth = 64
+)
+
+// parser is a recursive descent parser over the grammar:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand ( op operand | "in" "(" operand { "," operand } ")" )
+//	operand    = field | number | "null"
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.accept(kind, text) {
+		tok := p.peek()
+		return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected '%s', got %s", text, tok)}
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokenOperator, "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokenOperator, "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// enter records one more level of nesting at tok, failing once maxDepth is exceeded.
+// Callers must call leave when done parsing the nested expression.
+func (p *parser) enter(tok token) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expression nested deeper than %d levels", maxDepth)}
+	}
+
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); (tok.kind == tokenOperator && tok.text == "!") || tok.kind == tokenLParen {
+		if err := p.enter(tok); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+	}
+
+	if p.accept(tokenOperator, "!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{inner: inner}, nil
+	}
+
+	if p.accept(tokenLParen, "(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept(tokenIdent, "in") {
+		return p.parseIn(left)
+	}
+
+	tok := p.next()
+	if tok.kind != tokenOperator || !isComparison(tok.text) {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected a comparison operator, got %s", tok)}
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return compareNode{op: tok.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseIn(left operand) (node, error) {
+	if err := p.expect(tokenLParen, "("); err != nil {
+		return nil, err
+	}
+
+	var list []operand
+
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, item)
+
+		if !p.accept(tokenComma, ",") {
+			break
+		}
+	}
+
+	if err := p.expect(tokenRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	return inNode{left: left, list: list}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		value, err := parseNumber(tok.text)
+		if err != nil {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: err.Error()}
+		}
+
+		return literal{v: value}, nil
+
+	case tokenIdent:
+		if tok.text == "null" {
+			return literal{}, nil
+		}
+
+		if _, ok := fields[tok.text]; !ok {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unknown field '%s'", tok.text)}
+		}
+
+		return field{name: tok.text}, nil
+
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected a field or a number, got %s", tok)}
+	}
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseNumber parses a 0x-prefixed hex, decimal, or scientific notation (e.g. 1e18, 1.5e18) integer
+// of at most maxBits bits.
+func parseNumber(s string) (*big.Int, error) {
+	value, err := parseInteger(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if value.BitLen() > maxBits {
+		return nil, fmt.Errorf("number '%s' is wider than %d bits", s, maxBits)
+	}
+
+	return value, nil
+}
+
+func parseInteger(s string) (*big.Int, error) {
+	if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		return parseHex(hex)
+	}
+
+	if !strings.ContainsAny(s, ".eE") {
+		value, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid number '%s'", s)
+		}
+
+		return value, nil
+	}
+
+	f, _, err := big.ParseFloat(s, 10, precision, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number '%s'", s)
+	}
+
+	// Check the exponent before converting: f.Int would otherwise materialize numbers like
+	// 1e300000000 in full.
+	if f.MantExp(nil) > maxBits {
+		return nil, fmt.Errorf("number '%s' is wider than %d bits", s, maxBits)
+	}
+
+	value, accuracy := f.Int(nil)
+	if accuracy != big.Exact {
+		return nil, fmt.Errorf("number '%s' is not an integer", s)
+	}
+
+	return value, nil
+}
+
+// parseHex parses hex digits without their 0x prefix. An empty string, as in "0x", is zero.
+func parseHex(hex string) (*big.Int, error) {
+	if hex == "" {
+		return new(big.Int), nil
+	}
+
+	value, ok := new(big.Int).SetString(hex, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex number '0x%s'", hex)
+	}
+
+	return value, nil
+}
@@ -2,4 +2,9 @@ module github.com/aalbacetef/txnotify
 
 go 1.24.0
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.42.0
+)
+
+require golang.org/x/sys v0.36.0 // indirect
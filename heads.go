@@ -0,0 +1,73 @@
+package txnotify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+// HeadSubscriber is implemented by RPC clients that can push new block headers to the watcher.
+type HeadSubscriber interface {
+	SubscribeNewHeads() (*rpc.Subscription[ethereum.Header], error)
+}
+
+// listenHeads processes blocks as their headers are pushed by the node. If the subscription
+// drops, the watcher polls once every pollInterval until it can subscribe again. Clients
+// without subscription support fall back to polling.
+func (watcher *Watcher) listenHeads(ctx context.Context, subscriber HeadSubscriber) error {
+	for {
+		sub, err := subscriber.SubscribeNewHeads()
+
+		switch {
+		case errors.Is(err, rpc.ErrSubscriptionsNotSupported):
+			return watcher.poll(ctx)
+
+		case err != nil:
+			watcher.logger.Error("could not subscribe to new heads", "error", err)
+
+		default:
+			watcher.logger.Info("subscribed to new heads", "subscription", sub.ID)
+
+			// catch up on blocks produced before the subscription started.
+			watcher.checkNewBlock()
+			watcher.catchUp(ctx)
+
+			watcher.consumeHeads(ctx, sub)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-time.After(watcher.pollInterval):
+			watcher.checkNewBlock()
+			watcher.catchUp(ctx)
+		}
+	}
+}
+
+// consumeHeads processes new blocks until the subscription ends or ctx is done.
+func (watcher *Watcher) consumeHeads(ctx context.Context, sub *rpc.Subscription[ethereum.Header]) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := sub.Unsubscribe(); err != nil {
+				watcher.logger.Debug("could not unsubscribe from new heads", "error", err)
+			}
+
+			return
+
+		case header, ok := <-sub.C():
+			if !ok {
+				watcher.logger.Warn("new heads subscription ended", "error", sub.Err())
+				return
+			}
+
+			watcher.setLatestBlock(header.Number)
+			watcher.catchUp(ctx)
+		}
+	}
+}
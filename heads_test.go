@@ -0,0 +1,161 @@
+package txnotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+// wsChainNode serves a mockChain over a websocket JSON-RPC endpoint and pushes heads on demand.
+type wsChainNode struct {
+	chain    *mockChain
+	upgrader websocket.Upgrader
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	sub  chan struct{}
+}
+
+func (node *wsChainNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := node.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var req rpc.Request
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+
+		switch req.Method {
+		case "eth_blockNumber":
+			head, _ := node.chain.GetCurrentBlockNumber()
+			resp["result"] = head.Result
+
+		case "eth_getBlockByNumber":
+			block, err := node.chain.GetBlockByNumber(req.Params[0].(string)) //nolint:forcetypeassert
+			if err != nil {
+				resp["error"] = rpc.JSONRPCError{Code: -32000, Message: err.Error()}
+			} else {
+				resp["result"] = block.Result
+			}
+
+		case "eth_getBlockReceipts":
+			receipts, err := node.chain.GetBlockReceipts(req.Params[0].(string)) //nolint:forcetypeassert
+			if err != nil {
+				resp["error"] = rpc.JSONRPCError{Code: -32000, Message: err.Error()}
+			} else {
+				resp["result"] = receipts.Result
+			}
+
+		case "eth_subscribe":
+			resp["result"] = "0xsub"
+		}
+
+		if err := node.write(conn, resp); err != nil {
+			return
+		}
+
+		if req.Method == "eth_subscribe" {
+			node.mu.Lock()
+			node.conn = conn
+			node.mu.Unlock()
+			close(node.sub)
+		}
+	}
+}
+
+func (node *wsChainNode) write(conn *websocket.Conn, v any) error {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	return conn.WriteJSON(v) //nolint:wrapcheck
+}
+
+func (node *wsChainNode) pushHead(t *testing.T, num string) {
+	t.Helper()
+
+	node.mu.Lock()
+	conn := node.conn
+	node.mu.Unlock()
+
+	notification := map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "eth_subscription",
+		"params": map[string]any{
+			"subscription": "0xsub",
+			"result":       ethereum.Header{Number: num},
+		},
+	}
+
+	data, _ := json.Marshal(notification)
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatalf("could not push head: %v", err)
+	}
+}
+
+func TestWatcherListensForHeads(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.setHead("0x10")
+
+	node := &wsChainNode{chain: chain, sub: make(chan struct{})}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	client, err := rpc.NewClient(rpc.ClientOptions{Endpoint: "ws" + strings.TrimPrefix(server.URL, "http")})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, client, notifier)
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := watcher.Listen(ctx); err != nil {
+			t.Errorf("listen failed: %v", err)
+		}
+	}()
+
+	defer watcher.Close()
+
+	select {
+	case <-node.sub:
+	case <-time.After(time.Second):
+		t.Fatalf("watcher did not subscribe to new heads")
+	}
+
+	chain.addBlock("0x11", "0xh11", "0xh10", ethereum.Transaction{Hash: "0xtxa", From: alice})
+	chain.setHead("0x11")
+	node.pushHead(t, "0x11")
+
+	// the poll interval is far longer than the wait, so the block must have been pushed.
+	notifier.waitFor(t, EventMined, alice, "0xtxa")
+}
@@ -14,7 +14,7 @@ func strToHex(s string) (int, error) {
 		return 0, ErrBadFormat
 	}
 
-	v, err := strconv.ParseInt(strings.TrimLeft(s, "0x"), 16, 64)
+	v, err := strconv.ParseInt(strings.TrimPrefix(s, "0x"), 16, 64)
 	if err != nil {
 		return 0, fmt.Errorf("could not parse hex value: %w", err)
 	}
@@ -26,7 +26,9 @@ func numToStr(v int) string {
 	return fmt.Sprintf("%#0x", v)
 }
 
-func normalizeAddress(s string) string {
+// NormalizeAddress returns an address the way the Watcher stores and notifies it:
+// lowercase, with leading zeros dropped.
+func NormalizeAddress(s string) string {
 	pfx := "0x"
 	addr := strings.TrimPrefix(s, pfx)
 
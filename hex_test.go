@@ -20,6 +20,17 @@ func TestHex(t *testing.T) {
 		}
 	})
 
+	t.Run("it parses zero", func(tt *testing.T) {
+		got, err := strToHex("0x0")
+		if err != nil {
+			tt.Fatalf("error: %v", err)
+		}
+
+		if got != 0 {
+			tt.Fatalf("got: %#0x, want 0x0", got)
+		}
+	})
+
 	t.Run("it fails if the string is not prefixed", func(tt *testing.T) {
 		const testStr = "1692"
 		wantErr := ErrBadFormat
@@ -34,7 +45,7 @@ func TestNormalizeAddress(t *testing.T) {
 	v := "0x0000000012"
 	want := "0x12"
 
-	normed := normalizeAddress(v)
+	normed := NormalizeAddress(v)
 	if normed == want {
 		return
 	}
@@ -0,0 +1,359 @@
+// Package jsonl implements a txnotify.Notifier that appends every event as a line of JSON to a
+// file, rotated by size or age, or to stdout. Replay reads them back into another Notifier.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+)
+
+// Stdout is the Path that writes events to the standard output.
+const Stdout = "-"
+
+var (
+	ErrMissingPath = errors.New("missing jsonl path")
+	ErrClosed      = errors.New("jsonl notifier is closed")
+)
+
+type Options struct {
+	// Path is the file events are appended to, or Stdout. Rotation and syncing only apply to files.
+	Path string
+
+	// MaxSize rotates the file before a write would make it larger than this many bytes.
+	MaxSize int64
+
+	// MaxAge rotates the file once it has been written to for this long.
+	MaxAge time.Duration
+
+	// MaxBackups is how many rotated files are kept, the oldest are removed. Zero keeps them all.
+	MaxBackups int
+
+	// Sync fsyncs the file after every event. Otherwise, SyncInterval fsyncs it on the first
+	// write after that long since the last sync; with neither, syncing is left to the OS.
+	// The file is always synced when rotated or closed.
+	Sync         bool
+	SyncInterval time.Duration
+}
+
+const (
+	perm = 0o600
+
+	// rotated files are named after the file and the time they were rotated at, e.g.
+	// events-20261017T155748.123456789.jsonl.
+	backupTimeFormat = "20060102T150405.000000000"
+
+	// rotateRetryDelay is how long the file is appended to after failing to rotate it, before
+	// rotating it is tried again.
+	rotateRetryDelay = time.Minute
+)
+
+type Notifier struct {
+	mu      sync.Mutex
+	options Options
+
+	writer   io.Writer
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	syncedAt time.Time
+	closed   bool
+
+	// rotateFailedAt is when rotating the file last failed, see rotateRetryDelay.
+	rotateFailedAt time.Time
+
+	logger *slog.Logger
+
+	// now is swapped in tests.
+	now func() time.Time
+}
+
+// NewNotifier opens (or creates) the file at options.Path to append events to.
+func NewNotifier(options Options) (*Notifier, error) {
+	n := &Notifier{options: options, now: time.Now, logger: slog.Default()}
+
+	if options.Path == Stdout {
+		n.writer = os.Stdout
+		return n, nil
+	}
+
+	if options.Path == "" {
+		return nil, ErrMissingPath
+	}
+
+	if err := n.open(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Notify appends the event to the file as one line, rotating it first if needed.
+func (n *Notifier) Notify(event txnotify.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	data = append(data, '\n')
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return ErrClosed
+	}
+
+	if n.options.Path == Stdout {
+		if _, err := n.writer.Write(data); err != nil {
+			return fmt.Errorf("could not write event: %w", err)
+		}
+
+		return nil
+	}
+
+	// the file could not be reopened after a failed rotation.
+	if n.file == nil {
+		if err := n.open(); err != nil {
+			return err
+		}
+	}
+
+	if n.shouldRotate(int64(len(data))) {
+		if err := n.rotate(); err != nil {
+			if n.file == nil {
+				return err
+			}
+
+			// the event is written all the same, so it must not be retried.
+			n.rotateFailedAt = n.now()
+			n.logger.Error("could not rotate events file, appending to it", "path", n.options.Path, "error", err)
+		}
+	}
+
+	written, err := n.file.Write(data)
+	n.size += int64(written)
+
+	if err != nil {
+		return fmt.Errorf("could not write event: %w", err)
+	}
+
+	if n.options.Sync || (n.options.SyncInterval > 0 && n.now().Sub(n.syncedAt) >= n.options.SyncInterval) {
+		return n.sync()
+	}
+
+	return nil
+}
+
+// Close syncs and closes the file. Stdout is left open.
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil
+	}
+
+	n.closed = true
+
+	if n.file == nil {
+		return nil
+	}
+
+	return n.closeFile()
+}
+
+// shouldRotate reports whether the file must be rotated before writing size bytes to it. Empty
+// files are never rotated, so an event larger than MaxSize still gets written, and neither are
+// files that failed to rotate less than rotateRetryDelay ago.
+func (n *Notifier) shouldRotate(size int64) bool {
+	if n.size == 0 || (!n.rotateFailedAt.IsZero() && n.now().Sub(n.rotateFailedAt) < rotateRetryDelay) {
+		return false
+	}
+
+	if n.options.MaxSize > 0 && n.size+size > n.options.MaxSize {
+		return true
+	}
+
+	return n.options.MaxAge > 0 && n.now().Sub(n.openedAt) >= n.options.MaxAge
+}
+
+// rotate renames the file to a timestamped backup, opens a new one and prunes old backups.
+// If that fails, the current file is reopened to keep appending to it; if it cannot be, n.file
+// is left nil and the next write reopens it.
+func (n *Notifier) rotate() error {
+	err := n.closeFile()
+	n.file = nil
+
+	if err != nil {
+		return errors.Join(err, n.open())
+	}
+
+	if err := os.Rename(n.options.Path, backupName(n.options.Path, n.now())); err != nil {
+		// keep appending to the current file.
+		return errors.Join(fmt.Errorf("could not rotate %s: %w", n.options.Path, err), n.open())
+	}
+
+	if err := n.open(); err != nil {
+		return err
+	}
+
+	return n.prune()
+}
+
+func (n *Notifier) open() error {
+	file, err := os.OpenFile(n.options.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", n.options.Path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not stat %s: %w", n.options.Path, err)
+	}
+
+	n.file = file
+	n.size = info.Size()
+	n.openedAt = n.now()
+	n.syncedAt = n.openedAt
+
+	return nil
+}
+
+func (n *Notifier) sync() error {
+	if err := n.file.Sync(); err != nil {
+		return fmt.Errorf("could not sync %s: %w", n.options.Path, err)
+	}
+
+	n.syncedAt = n.now()
+
+	return nil
+}
+
+func (n *Notifier) closeFile() error {
+	if err := n.sync(); err != nil {
+		n.file.Close()
+		return err
+	}
+
+	if err := n.file.Close(); err != nil {
+		return fmt.Errorf("could not close %s: %w", n.options.Path, err)
+	}
+
+	return nil
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (n *Notifier) prune() error {
+	if n.options.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := Backups(n.options.Path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for len(backups) > n.options.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			errs = append(errs, fmt.Errorf("could not remove %s: %w", backups[0], err))
+		}
+
+		backups = backups[1:]
+	}
+
+	return errors.Join(errs...)
+}
+
+// backupName inserts the rotation time before the extension of path.
+func backupName(path string, at time.Time) string {
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "-" + at.UTC().Format(backupTimeFormat) + ext
+}
+
+// Backups returns the files rotated out of path, oldest first.
+func Backups(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return nil, fmt.Errorf("could not list backups of %s: %w", path, err)
+	}
+
+	var backups []string
+
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext)); err != nil {
+			continue
+		}
+
+		backups = append(backups, filepath.Join(dir, entry.Name()))
+	}
+
+	// the timestamps sort chronologically.
+	slices.Sort(backups)
+
+	return backups, nil
+}
+
+// Replay decodes the events written by a Notifier from r and passes them to notifier in order.
+// It returns how many were delivered, along with the errors of the others.
+func Replay(r io.Reader, notifier txnotify.Notifier) (int, error) {
+	const maxLineSize = 16 << 20
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+
+	var (
+		delivered int
+		errs      []error
+	)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		event := txnotify.Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			errs = append(errs, fmt.Errorf("could not decode line %d: %w", line, err))
+			continue
+		}
+
+		if err := notifier.Notify(event); err != nil {
+			errs = append(errs, fmt.Errorf("could not deliver line %d: %w", line, err))
+			continue
+		}
+
+		delivered++
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("could not read events: %w", err))
+	}
+
+	return delivered, errors.Join(errs...)
+}
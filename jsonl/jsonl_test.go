@@ -0,0 +1,312 @@
+package jsonl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+type recordingNotifier struct {
+	events []txnotify.Event
+}
+
+func (r *recordingNotifier) Notify(event txnotify.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func testEvent(seq uint64) txnotify.Event {
+	return txnotify.Event{
+		Version:      txnotify.EventVersion,
+		Kind:         txnotify.EventMined,
+		Address:      "0xa11ce",
+		Sequence:     seq,
+		Transactions: []ethereum.Transaction{{Hash: "0xtx", From: "0xa11ce"}},
+	}
+}
+
+// clock is a fake time source advanced by hand.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func setup(t *testing.T, options Options) (*Notifier, *clock) {
+	t.Helper()
+
+	options.Path = filepath.Join(t.TempDir(), "events.jsonl")
+
+	notifier, err := NewNotifier(options)
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	t.Cleanup(func() { notifier.Close() })
+
+	c := &clock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	notifier.now = c.Now
+	notifier.openedAt = c.now
+	notifier.syncedAt = c.now
+
+	return notifier, c
+}
+
+func notifyAll(t *testing.T, notifier *Notifier, c *clock, from, to uint64) {
+	t.Helper()
+
+	for seq := from; seq <= to; seq++ {
+		// distinct rotation times, so backups do not overwrite each other.
+		c.now = c.now.Add(time.Millisecond)
+
+		if err := notifier.Notify(testEvent(seq)); err != nil {
+			t.Fatalf("could not notify %d: %v", seq, err)
+		}
+	}
+}
+
+// replayAll reads back the events of the backups and the current file, oldest first.
+func replayAll(t *testing.T, path string) []uint64 {
+	t.Helper()
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatalf("could not list backups: %v", err)
+	}
+
+	recorder := &recordingNotifier{}
+
+	for _, file := range append(backups, path) {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("could not open %s: %v", file, err)
+		}
+
+		_, err = Replay(f, recorder)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("could not replay %s: %v", file, err)
+		}
+	}
+
+	var seqs []uint64
+	for _, event := range recorder.events {
+		seqs = append(seqs, event.Sequence)
+	}
+
+	return seqs
+}
+
+func sequence(from, to uint64) []uint64 {
+	var seqs []uint64
+	for seq := from; seq <= to; seq++ {
+		seqs = append(seqs, seq)
+	}
+
+	return seqs
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("it appends events as lines of JSON", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{Sync: true})
+		notifyAll(tt, notifier, c, 1, 3)
+
+		if err := notifier.Close(); err != nil {
+			tt.Fatalf("could not close: %v", err)
+		}
+
+		// reopening appends to the same file.
+		reopened, err := NewNotifier(notifier.options)
+		if err != nil {
+			tt.Fatalf("could not reopen: %v", err)
+		}
+
+		notifyAll(tt, reopened, c, 4, 5)
+		reopened.Close()
+
+		data, err := os.ReadFile(notifier.options.Path)
+		if err != nil {
+			tt.Fatalf("could not read file: %v", err)
+		}
+
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 5 {
+			tt.Fatalf("got %d lines, want 5:\n%s", len(lines), data)
+		}
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(1, 5)) {
+			tt.Fatalf("got %v, want %v", got, sequence(1, 5))
+		}
+	})
+
+	t.Run("it rotates the file by size", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{})
+
+		notifyAll(tt, notifier, c, 1, 1)
+		notifier.options.MaxSize = 2*notifier.size + 1
+
+		notifyAll(tt, notifier, c, 2, 7)
+
+		backups, err := Backups(notifier.options.Path)
+		if err != nil {
+			tt.Fatalf("could not list backups: %v", err)
+		}
+
+		if len(backups) != 3 {
+			tt.Fatalf("got %d backups, want 3: %v", len(backups), backups)
+		}
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(1, 7)) {
+			tt.Fatalf("got %v, want %v", got, sequence(1, 7))
+		}
+	})
+
+	t.Run("it keeps writing after a failed rotation", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{})
+
+		notifyAll(tt, notifier, c, 1, 1)
+		notifier.options.MaxSize = notifier.size + 1
+
+		// closing the file under the notifier makes syncing it on rotation fail.
+		notifier.file.Close()
+
+		notifyAll(tt, notifier, c, 2, 3)
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(1, 3)) {
+			tt.Fatalf("got %v, want %v", got, sequence(1, 3))
+		}
+	})
+
+	t.Run("it keeps appending while the file cannot be renamed", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{})
+
+		notifyAll(tt, notifier, c, 1, 1)
+		notifier.options.MaxSize = notifier.size + 1
+
+		// a directory in the way of every backup name makes renaming the file fail.
+		for seq := range 4 {
+			blocker := backupName(notifier.options.Path, c.now.Add(time.Duration(seq+1)*time.Millisecond))
+			if err := os.MkdirAll(filepath.Join(blocker, "blocker"), 0o700); err != nil {
+				tt.Fatalf("could not create %s: %v", blocker, err)
+			}
+		}
+
+		notifyAll(tt, notifier, c, 2, 5)
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(1, 5)) {
+			tt.Fatalf("got %v, want %v", got, sequence(1, 5))
+		}
+
+		// rotating is tried again once rotateRetryDelay has passed.
+		c.now = c.now.Add(rotateRetryDelay)
+		notifyAll(tt, notifier, c, 6, 6)
+
+		backups, err := Backups(notifier.options.Path)
+		if err != nil || len(backups) != 1 {
+			tt.Fatalf("got backups %v (error: %v), want 1", backups, err)
+		}
+	})
+
+	t.Run("it rotates the file by age", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{MaxAge: time.Hour})
+
+		notifyAll(tt, notifier, c, 1, 2)
+
+		c.now = c.now.Add(time.Hour)
+		notifyAll(tt, notifier, c, 3, 3)
+
+		if backups, _ := Backups(notifier.options.Path); len(backups) != 1 {
+			tt.Fatalf("got %d backups, want 1", len(backups))
+		}
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(1, 3)) {
+			tt.Fatalf("got %v, want %v", got, sequence(1, 3))
+		}
+	})
+
+	t.Run("it keeps the newest MaxBackups", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{MaxBackups: 2})
+
+		notifyAll(tt, notifier, c, 1, 1)
+		notifier.options.MaxSize = notifier.size
+
+		notifyAll(tt, notifier, c, 2, 6)
+
+		backups, err := Backups(notifier.options.Path)
+		if err != nil {
+			tt.Fatalf("could not list backups: %v", err)
+		}
+
+		if len(backups) != 2 {
+			tt.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+		}
+
+		if got := replayAll(tt, notifier.options.Path); !slices.Equal(got, sequence(4, 6)) {
+			tt.Fatalf("got %v, want %v", got, sequence(4, 6))
+		}
+	})
+
+	t.Run("it syncs every SyncInterval", func(tt *testing.T) {
+		notifier, c := setup(tt, Options{SyncInterval: time.Second})
+		start := c.now
+
+		notifyAll(tt, notifier, c, 1, 1)
+
+		if !notifier.syncedAt.Equal(start) {
+			tt.Fatalf("synced before the interval")
+		}
+
+		c.now = c.now.Add(time.Second)
+		notifyAll(tt, notifier, c, 2, 2)
+
+		if !notifier.syncedAt.Equal(c.now) {
+			tt.Fatalf("got last sync at %s, want %s", notifier.syncedAt, c.now)
+		}
+	})
+
+	t.Run("it refuses events after Close", func(tt *testing.T) {
+		notifier, _ := setup(tt, Options{})
+
+		if err := notifier.Close(); err != nil {
+			tt.Fatalf("could not close: %v", err)
+		}
+
+		if err := notifier.Notify(testEvent(1)); !errors.Is(err, ErrClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrClosed)
+		}
+	})
+
+	t.Run("it requires a path", func(tt *testing.T) {
+		if _, err := NewNotifier(Options{}); !errors.Is(err, ErrMissingPath) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrMissingPath)
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	input := `{"version":1,"kind":"mined","address":"0xa11ce","sequence":1}
+
+not json
+{"version":1,"kind":"mined","address":"0xa11ce","sequence":2}
+`
+
+	recorder := &recordingNotifier{}
+
+	delivered, err := Replay(strings.NewReader(input), recorder)
+	if delivered != 2 || len(recorder.events) != 2 || recorder.events[1].Sequence != 2 {
+		t.Fatalf("got %d delivered (%+v), want 2", delivered, recorder.events)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("got: '%v', want an error for line 3", err)
+	}
+}
@@ -0,0 +1,297 @@
+package txnotify
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+var ErrRateLimited = errors.New("address is over its notification rate limit")
+
+// Middleware wraps a Notifier to change which events reach it, or how.
+type Middleware func(next Notifier) Notifier
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(event Event) error
+
+func (fn NotifierFunc) Notify(event Event) error {
+	return fn(event)
+}
+
+// Chain wraps notifier with the middlewares, the first one being the outermost, so events go
+// through them in the order they are given.
+func Chain(notifier Notifier, middlewares ...Middleware) Notifier {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		notifier = middlewares[i](notifier)
+	}
+
+	return notifier
+}
+
+// dedupKey identifies an item notified for an address: a transaction, or a transfer within one.
+type dedupKey struct {
+	kind    EventKind
+	address string
+	hash    string
+	item    string
+}
+
+// Dedup drops the transactions and transfers already notified for an address with the same
+// kind of event within ttl, skipping events left empty. Reverting a transaction makes it
+// notifiable again, so it is not dropped if it is mined again. Items are only remembered once
+// the next Notifier accepts them, so the Watcher's retries go through.
+func Dedup(ttl time.Duration) Middleware {
+	return func(next Notifier) Notifier {
+		var (
+			mu      sync.Mutex
+			seen    = make(map[dedupKey]time.Time)
+			sweptAt = time.Now()
+		)
+
+		// sweep forgets expired items, at most once per ttl. Callers must hold mu.
+		sweep := func(now time.Time) {
+			if now.Sub(sweptAt) < ttl {
+				return
+			}
+
+			for key, expires := range seen {
+				if now.After(expires) {
+					delete(seen, key)
+				}
+			}
+
+			sweptAt = now
+		}
+
+		return NotifierFunc(func(event Event) error {
+			now := time.Now()
+
+			mu.Lock()
+			sweep(now)
+
+			isNew := func(key dedupKey) bool {
+				expires, ok := seen[key]
+				return !ok || now.After(expires)
+			}
+
+			filtered := event
+			filtered.Transactions = filterSlice(event.Transactions, func(tx ethereum.Transaction) bool {
+				return isNew(dedupKey{kind: event.Kind, address: event.Address, hash: tx.Hash})
+			})
+			filtered.TokenTransfers = filterSlice(event.TokenTransfers, func(transfer ethereum.TokenTransfer) bool {
+				return isNew(tokenTransferKey(event, transfer))
+			})
+			filtered.NFTTransfers = filterSlice(event.NFTTransfers, func(transfer ethereum.NFTTransfer) bool {
+				return isNew(nftTransferKey(event, transfer))
+			})
+			mu.Unlock()
+
+			if filtered.Empty() {
+				return nil
+			}
+
+			if err := next.Notify(filtered); err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			expires := now.Add(ttl)
+
+			for _, tx := range filtered.Transactions {
+				seen[dedupKey{kind: event.Kind, address: event.Address, hash: tx.Hash}] = expires
+			}
+
+			// a reverted transaction may be included again in the new chain, and reverted again.
+			switch event.Kind {
+			case EventReverted:
+				forget(seen, event, EventMined, EventUnconfirmed, EventDeployed)
+			case EventMined, EventUnconfirmed, EventDeployed:
+				forget(seen, event, EventReverted)
+			}
+
+			for _, transfer := range filtered.TokenTransfers {
+				seen[tokenTransferKey(event, transfer)] = expires
+			}
+
+			for _, transfer := range filtered.NFTTransfers {
+				seen[nftTransferKey(event, transfer)] = expires
+			}
+
+			return nil
+		})
+	}
+}
+
+// forget removes the items of the transactions in event notified with one of kinds.
+func forget(seen map[dedupKey]time.Time, event Event, kinds ...EventKind) {
+	hashes := make(map[string]bool, len(event.Transactions))
+	for _, tx := range event.Transactions {
+		hashes[tx.Hash] = true
+	}
+
+	for key := range seen {
+		if key.address == event.Address && hashes[key.hash] && slices.Contains(kinds, key.kind) {
+			delete(seen, key)
+		}
+	}
+}
+
+func tokenTransferKey(event Event, transfer ethereum.TokenTransfer) dedupKey {
+	return dedupKey{
+		kind:    event.Kind,
+		address: event.Address,
+		hash:    transfer.TransactionHash,
+		item:    "token:" + transfer.LogIndex,
+	}
+}
+
+func nftTransferKey(event Event, transfer ethereum.NFTTransfer) dedupKey {
+	return dedupKey{
+		kind:    event.Kind,
+		address: event.Address,
+		hash:    transfer.TransactionHash,
+		item:    "nft:" + transfer.LogIndex + ":" + transfer.TokenID,
+	}
+}
+
+// filterSlice returns the items kept by keep, or nil if there are none.
+func filterSlice[T any](items []T, keep func(T) bool) []T {
+	var kept []T
+
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+
+	return kept
+}
+
+// rateLimiter is a token bucket per address, holding up to limit tokens refilled at limit per period.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	rate    float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(limit int, per time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   float64(limit),
+		rate:    float64(limit) / per.Seconds(),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// take consumes a token of address. If none is available, it returns how long until there is
+// one; wait then reserves that token, otherwise nothing is consumed.
+func (limiter *rateLimiter) take(address string, wait bool) time.Duration {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+
+	b, ok := limiter.buckets[address]
+	if !ok {
+		b = &bucket{tokens: limiter.limit, updated: now}
+		limiter.buckets[address] = b
+	}
+
+	b.tokens = min(limiter.limit, b.tokens+now.Sub(b.updated).Seconds()*limiter.rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	delay := time.Duration((1 - b.tokens) / limiter.rate * float64(time.Second))
+
+	if wait {
+		b.tokens--
+	}
+
+	return delay
+}
+
+// RateLimit refuses the events of an address beyond limit per period with ErrRateLimited,
+// which the Watcher retries with its backoff and eventually dead-letters. Bursts of up to
+// limit events go through at once.
+func RateLimit(limit int, per time.Duration) Middleware {
+	return func(next Notifier) Notifier {
+		limiter := newRateLimiter(max(limit, 1), per)
+
+		return NotifierFunc(func(event Event) error {
+			if limiter.take(event.Address, false) > 0 {
+				return ErrRateLimited
+			}
+
+			return next.Notify(event)
+		})
+	}
+}
+
+// Throttle delays the events of an address beyond limit per period until they fit the rate,
+// instead of refusing them. Delays hold up the Watcher's worker for the address, and with it
+// the other addresses sharing it. See Throttler.
+func Throttle(limit int, per time.Duration) Middleware {
+	return func(next Notifier) Notifier {
+		return NewThrottler(next, limit, per)
+	}
+}
+
+// Throttler delays the events of an address beyond a rate until they fit it. Close interrupts
+// the delays, failing the delayed events with ErrNotifierClosed.
+type Throttler struct {
+	next    Notifier
+	limiter *rateLimiter
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewThrottler returns a Throttler passing at most limit events per period of an address to next.
+func NewThrottler(next Notifier, limit int, per time.Duration) *Throttler {
+	return &Throttler{
+		next:    next,
+		limiter: newRateLimiter(max(limit, 1), per),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Notify passes the event to the next Notifier once it fits the rate of its address.
+func (t *Throttler) Notify(event Event) error {
+	if delay := t.limiter.take(event.Address, true); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-t.stop:
+			return ErrNotifierClosed
+		}
+	}
+
+	select {
+	case <-t.stop:
+		return ErrNotifierClosed
+	default:
+	}
+
+	return t.next.Notify(event)
+}
+
+// Close stops accepting events and interrupts the delayed ones.
+func (t *Throttler) Close() {
+	t.stopOnce.Do(func() { close(t.stop) })
+}
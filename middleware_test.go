@@ -0,0 +1,435 @@
+package txnotify
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func txEvent(kind EventKind, address, block string, hashes ...string) Event {
+	event := Event{Kind: kind, Address: address, BlockNumber: block}
+
+	for _, hash := range hashes {
+		event.Transactions = append(event.Transactions, ethereum.Transaction{Hash: hash})
+	}
+
+	return event
+}
+
+// hashes returns the transaction hashes of every recorded event, one slice per event.
+func hashes(events []Event) [][]string {
+	var all [][]string
+
+	for _, event := range events {
+		var eventHashes []string
+		for _, tx := range event.Transactions {
+			eventHashes = append(eventHashes, tx.Hash)
+		}
+
+		all = append(all, eventHashes)
+	}
+
+	return all
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+
+	tag := func(name string) Middleware {
+		return func(next Notifier) Notifier {
+			return NotifierFunc(func(event Event) error {
+				order = append(order, name)
+				return next.Notify(event)
+			})
+		}
+	}
+
+	recorder := newRecordingNotifier()
+
+	if err := Chain(recorder, tag("first"), tag("second")).Notify(txEvent(EventMined, "0xa11ce", "0x1", "0xtx")); err != nil {
+		t.Fatalf("could not notify: %v", err)
+	}
+
+	if !slices.Equal(order, []string{"first", "second"}) || len(recorder.all()) != 1 {
+		t.Fatalf("got %v and %d events, want [first second] and 1", order, len(recorder.all()))
+	}
+}
+
+func TestDedup(t *testing.T) {
+	const alice, bob = "0xa11ce", "0xb0b"
+
+	t.Run("it drops transactions already notified", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Dedup(time.Minute))
+
+		for _, event := range []Event{
+			txEvent(EventMined, alice, "0x1", "0xtx1"),
+			txEvent(EventMined, alice, "0x1", "0xtx1", "0xtx2"),
+			txEvent(EventMined, alice, "0x1", "0xtx2"),
+			txEvent(EventMined, bob, "0x1", "0xtx1"),
+		} {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		want := [][]string{{"0xtx1"}, {"0xtx2"}, {"0xtx1"}}
+		if got := hashes(recorder.all()); !slices.EqualFunc(got, want, slices.Equal) {
+			tt.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("it keeps each kind of event", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Dedup(time.Minute))
+
+		for _, event := range []Event{
+			txEvent(EventPending, alice, "", "0xtx1"),
+			txEvent(EventMined, alice, "0x1", "0xtx1"),
+			txEvent(EventReverted, alice, "0x1", "0xtx1"),
+			txEvent(EventMined, alice, "0x2", "0xtx1"),
+			txEvent(EventReverted, alice, "0x2", "0xtx1"),
+			txEvent(EventReverted, alice, "0x2", "0xtx1"),
+		} {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		var kinds []EventKind
+		for _, event := range recorder.all() {
+			kinds = append(kinds, event.Kind)
+		}
+
+		want := []EventKind{EventPending, EventMined, EventReverted, EventMined, EventReverted}
+		if !slices.Equal(kinds, want) {
+			tt.Fatalf("got %v, want %v", kinds, want)
+		}
+	})
+
+	t.Run("it drops transfers already notified", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Dedup(time.Minute))
+
+		event := Event{
+			Kind:    EventMined,
+			Address: alice,
+			TokenTransfers: []ethereum.TokenTransfer{
+				{TransactionHash: "0xtx1", LogIndex: "0x0"},
+				{TransactionHash: "0xtx1", LogIndex: "0x1"},
+			},
+		}
+
+		for range 2 {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		if got := recorder.all(); len(got) != 1 || len(got[0].TokenTransfers) != 2 {
+			tt.Fatalf("got %+v, want one event with both transfers", got)
+		}
+	})
+
+	t.Run("it does not remember refused events", func(tt *testing.T) {
+		flaky := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 1}
+		notifier := Chain(flaky, Dedup(time.Minute))
+
+		event := txEvent(EventMined, alice, "0x1", "0xtx1")
+
+		if err := notifier.Notify(event); !errors.Is(err, errUnavailable) {
+			tt.Fatalf("got: '%v', want: '%v'", err, errUnavailable)
+		}
+
+		if err := notifier.Notify(event); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if got := flaky.all(); len(got) != 1 {
+			tt.Fatalf("got %d events, want the retried one", len(got))
+		}
+	})
+
+	t.Run("it forgets transactions after the ttl", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Dedup(10*time.Millisecond))
+
+		event := txEvent(EventMined, alice, "0x1", "0xtx1")
+
+		notifier.Notify(event)
+		time.Sleep(20 * time.Millisecond)
+		notifier.Notify(event)
+
+		if got := recorder.all(); len(got) != 2 {
+			tt.Fatalf("got %d events, want 2", len(got))
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	const alice, bob = "0xa11ce", "0xb0b"
+
+	t.Run("it refuses events over the limit", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, RateLimit(2, time.Hour))
+
+		for i := range 3 {
+			err := notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx"))
+			if i < 2 && err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+
+			if i == 2 && !errors.Is(err, ErrRateLimited) {
+				tt.Fatalf("got: '%v', want: '%v'", err, ErrRateLimited)
+			}
+		}
+
+		if err := notifier.Notify(txEvent(EventMined, bob, "0x1", "0xtx")); err != nil {
+			tt.Fatalf("other addresses should not be limited, got: '%v'", err)
+		}
+
+		if got := recorder.all(); len(got) != 3 {
+			tt.Fatalf("got %d events, want 3", len(got))
+		}
+	})
+
+	t.Run("it refills the limit over time", func(tt *testing.T) {
+		notifier := Chain(newRecordingNotifier(), RateLimit(1, 20*time.Millisecond))
+
+		notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx"))
+
+		if err := notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx")); !errors.Is(err, ErrRateLimited) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrRateLimited)
+		}
+
+		time.Sleep(25 * time.Millisecond)
+
+		if err := notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+	})
+
+	t.Run("it delays events over the limit when throttling", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Throttle(1, 20*time.Millisecond))
+
+		start := time.Now()
+
+		for range 3 {
+			if err := notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx")); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+			tt.Fatalf("3 events took %s, want about 40ms", elapsed)
+		}
+
+		if got := recorder.all(); len(got) != 3 {
+			tt.Fatalf("got %d events, want 3", len(got))
+		}
+	})
+
+	t.Run("it interrupts throttled events on Close", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		throttler := NewThrottler(recorder, 1, time.Hour)
+
+		if err := throttler.Notify(txEvent(EventMined, alice, "0x1", "0xtx")); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- throttler.Notify(txEvent(EventMined, alice, "0x2", "0xtx2")) }()
+
+		throttler.Close()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrNotifierClosed) {
+				tt.Fatalf("got: '%v', want: '%v'", err, ErrNotifierClosed)
+			}
+
+		case <-time.After(time.Second):
+			tt.Fatalf("the throttled event was not interrupted")
+		}
+
+		if got := recorder.all(); len(got) != 1 {
+			tt.Fatalf("got %d events, want 1", len(got))
+		}
+	})
+}
+
+func TestDigest(t *testing.T) {
+	const alice, bob = "0xa11ce", "0xb0b"
+
+	t.Run("it aggregates the events of several blocks", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Digest(2, 0))
+
+		for _, event := range []Event{
+			txEvent(EventMined, alice, "0x1", "0xtx1"),
+			txEvent(EventMined, bob, "0x1", "0xtx2"),
+			txEvent(EventMined, alice, "0x2", "0xtx3", "0xtx4"),
+			txEvent(EventMined, alice, "0x3", "0xtx5"),
+		} {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		got := recorder.all()
+		if len(got) != 1 || got[0].Address != alice || got[0].BlockNumber != "0x2" {
+			tt.Fatalf("got %+v, want one digest of alice's first 2 blocks", got)
+		}
+
+		if want := [][]string{{"0xtx1", "0xtx3", "0xtx4"}}; !slices.EqualFunc(hashes(got), want, slices.Equal) {
+			tt.Fatalf("got %v, want %v", hashes(got), want)
+		}
+	})
+
+	t.Run("it sends digests at the end of the interval", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Digest(0, 20*time.Millisecond))
+
+		notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+		notifier.Notify(txEvent(EventMined, alice, "0x2", "0xtx2"))
+
+		if got := recorder.all(); len(got) != 0 {
+			tt.Fatalf("got %d events before the interval ended, want none", len(got))
+		}
+
+		for deadline := time.Now().Add(time.Second); len(recorder.all()) == 0; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				tt.Fatalf("timed out waiting for the digest")
+			}
+		}
+
+		if want := [][]string{{"0xtx1", "0xtx2"}}; !slices.EqualFunc(hashes(recorder.all()), want, slices.Equal) {
+			tt.Fatalf("got %v, want %v", hashes(recorder.all()), want)
+		}
+	})
+
+	t.Run("it keeps the events of an address in order", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		notifier := Chain(recorder, Digest(3, 0))
+
+		notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+		notifier.Notify(txEvent(EventMined, alice, "0x2", "0xtx2"))
+		notifier.Notify(txEvent(EventReverted, alice, "0x2", "0xtx2"))
+
+		got := recorder.all()
+		if len(got) != 1 || got[0].Kind != EventMined || len(got[0].Transactions) != 2 {
+			tt.Fatalf("got %+v, want the mined digest sent before the reverted event is queued", got)
+		}
+	})
+
+	t.Run("it takes refused events back out", func(tt *testing.T) {
+		flaky := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 1}
+		notifier := Chain(flaky, Digest(2, 0))
+
+		notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+
+		event := txEvent(EventMined, alice, "0x2", "0xtx2")
+
+		if err := notifier.Notify(event); !errors.Is(err, errUnavailable) {
+			tt.Fatalf("got: '%v', want: '%v'", err, errUnavailable)
+		}
+
+		if err := notifier.Notify(event); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if want := [][]string{{"0xtx1", "0xtx2"}}; !slices.EqualFunc(hashes(flaky.all()), want, slices.Equal) {
+			tt.Fatalf("got %v, want %v", hashes(flaky.all()), want)
+		}
+	})
+
+	t.Run("it does not hold up other addresses while sending", func(tt *testing.T) {
+		gate := make(chan struct{})
+		recorder := newRecordingNotifier()
+
+		notifier := Chain(NotifierFunc(func(event Event) error {
+			if event.Address == alice {
+				<-gate
+			}
+
+			return recorder.Notify(event)
+		}), Digest(1, 0))
+
+		sent := make(chan struct{})
+
+		go func() {
+			notifier.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+			close(sent)
+		}()
+
+		done := make(chan struct{})
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			notifier.Notify(txEvent(EventMined, bob, "0x1", "0xtx2"))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			tt.Fatalf("bob's digest should not wait for alice's")
+		}
+
+		close(gate)
+		<-sent
+
+		if got := recorder.all(); len(got) != 2 || got[0].Address != bob {
+			tt.Fatalf("got %+v, want bob's digest before alice's", got)
+		}
+	})
+
+	t.Run("it gives up on digests refused MaxAttempts times", func(tt *testing.T) {
+		flaky := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 10}
+		failed := make(chan Event, 1)
+
+		digester := NewDigester(flaky, DigestOptions{
+			Interval:    5 * time.Millisecond,
+			MaxAttempts: 2,
+			OnError:     func(event Event, _ error) { failed <- event },
+		})
+
+		digester.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+
+		select {
+		case event := <-failed:
+			if event.Transactions[0].Hash != "0xtx1" {
+				tt.Fatalf("got %+v, want alice's digest", event)
+			}
+		case <-time.After(time.Second):
+			tt.Fatalf("timed out waiting for the digest to be given up on")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		if got := flaky.attemptCount(); got != 2 {
+			tt.Fatalf("got %d attempts, want 2", got)
+		}
+	})
+
+	t.Run("it sends pending digests on close", func(tt *testing.T) {
+		recorder := newRecordingNotifier()
+		digester := NewDigester(recorder, DigestOptions{Blocks: 5, Interval: time.Hour})
+
+		digester.Notify(txEvent(EventMined, alice, "0x1", "0xtx1"))
+		digester.Notify(txEvent(EventMined, bob, "0x1", "0xtx2"))
+		digester.Close()
+
+		if want := [][]string{{"0xtx1"}, {"0xtx2"}}; !slices.EqualFunc(hashes(recorder.all()), want, slices.Equal) {
+			tt.Fatalf("got %v, want %v", hashes(recorder.all()), want)
+		}
+
+		if err := digester.Notify(txEvent(EventMined, alice, "0x2", "0xtx3")); !errors.Is(err, ErrNotifierClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrNotifierClosed)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package txnotify
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrInvalidRoute   = errors.New("invalid route")
+	ErrNotifierClosed = errors.New("notifier is closed")
+)
+
+const defaultRouteQueueSize = 256
+
+// Route sends the events of a MultiNotifier matching its filters to one Notifier.
+type Route struct {
+	// Name identifies the route in logs.
+	Name string
+
+	Notifier Notifier
+
+	// Subscriptions narrow the events down to the addresses, transactions and transfers
+	// matched by any of them, see FilterEvent. No subscriptions routes every event.
+	Subscriptions []Subscription
+
+	// Kinds restricts the route to these kinds of events, none routes every kind.
+	Kinds []EventKind
+
+	// QueueSize is how many events can wait for the Notifier before DropPolicy applies.
+	QueueSize int
+
+	// DropPolicy defaults to DropNewest. DropNone is not allowed, as a stuck Notifier
+	// would then hold up every other route.
+	DropPolicy DropPolicy
+
+	// OnError is called with the events the Notifier did not accept and the ones dropped
+	// from the queue. They are logged by default.
+	OnError func(event Event, err error)
+}
+
+// route is a Route with its queue, delivering events in order on its own goroutine.
+type route struct {
+	Route
+	dispatcher *dispatcher
+}
+
+// MultiNotifier fans events out to several notifiers. Each route has its own queue and
+// goroutine, so a slow or failing Notifier only delays and loses its own events.
+type MultiNotifier struct {
+	mu     sync.RWMutex
+	routes []*route
+	closed bool
+}
+
+// NewMultiNotifier validates the routes and starts delivering to them.
+func NewMultiNotifier(routes ...Route) (*MultiNotifier, error) {
+	multi := &MultiNotifier{}
+
+	for i, r := range routes {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("route-%d", i)
+		}
+
+		if r.Notifier == nil {
+			return nil, fmt.Errorf("%w: %s has no notifier", ErrInvalidRoute, r.Name)
+		}
+
+		compiled := make([]Subscription, len(r.Subscriptions))
+
+		for j, sub := range r.Subscriptions {
+			var err error
+
+			if compiled[j], err = sub.Compiled(); err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrInvalidRoute, r.Name, err)
+			}
+		}
+
+		r.Subscriptions = compiled
+
+		if r.QueueSize == 0 {
+			r.QueueSize = defaultRouteQueueSize
+		}
+
+		switch r.DropPolicy {
+		case "":
+			r.DropPolicy = DropNewest
+		case DropNewest, DropOldest:
+		default:
+			return nil, fmt.Errorf("%w: %s has drop policy '%s'", ErrInvalidRoute, r.Name, r.DropPolicy)
+		}
+
+		if r.OnError == nil {
+			name := r.Name
+			r.OnError = func(event Event, err error) {
+				slog.Default().Warn(
+					"route did not deliver event",
+					"route", name,
+					"sequence", event.Sequence,
+					"address", event.Address,
+					"error", err,
+				)
+			}
+		}
+
+		multi.routes = append(multi.routes, &route{Route: r})
+	}
+
+	for _, r := range multi.routes {
+		r.dispatcher = newDispatcher(1, r.QueueSize, r.DropPolicy, r.deliver, r.OnError)
+	}
+
+	return multi, nil
+}
+
+// Notify queues the event on every route it matches. It does not wait for the notifiers,
+// whose errors are passed to each route's OnError instead of being returned, so the
+// Watcher does not retry an event the other routes already accepted.
+func (multi *MultiNotifier) Notify(event Event) error {
+	multi.mu.RLock()
+	defer multi.mu.RUnlock()
+
+	if multi.closed {
+		return ErrNotifierClosed
+	}
+
+	for _, r := range multi.routes {
+		if filtered, ok := r.filter(event); ok {
+			r.dispatcher.forward(filtered)
+		}
+	}
+
+	return nil
+}
+
+// Close stops accepting events and waits for every route to deliver its queued ones.
+func (multi *MultiNotifier) Close() {
+	multi.mu.Lock()
+
+	if multi.closed {
+		multi.mu.Unlock()
+		return
+	}
+
+	multi.closed = true
+	multi.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, r := range multi.routes {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			r.dispatcher.close()
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (r *route) filter(event Event) (Event, bool) {
+	if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, event.Kind) {
+		return event, false
+	}
+
+	if len(r.Subscriptions) == 0 {
+		return event, true
+	}
+
+	return FilterEvent(event, r.Subscriptions)
+}
+
+func (r *route) deliver(event Event) {
+	if err := r.Notifier.Notify(event); err != nil {
+		r.OnError(event, err)
+	}
+}
+
+// FilterEvent keeps the transactions and transfers of an event matched by any of the
+// subscriptions to its address, see Subscription.Matches and Subscription.MatchesTransfer. It reports false if there is no such subscription, or if
+// none of the event's contents are left.
+func FilterEvent(event Event, subs []Subscription) (Event, bool) {
+	var matching []Subscription
+
+	for _, sub := range subs {
+		if NormalizeAddress(sub.Address) == event.Address {
+			matching = append(matching, sub)
+		}
+	}
+
+	if len(matching) == 0 {
+		return event, false
+	}
+
+	filtered := filterContents(event, matching)
+
+	return filtered, !filtered.Empty()
+}
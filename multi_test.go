@@ -0,0 +1,220 @@
+package txnotify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// stuckNotifier blocks every Notify call until its gate is closed.
+type stuckNotifier struct {
+	*recordingNotifier
+	gate chan struct{}
+}
+
+func (s *stuckNotifier) Notify(event Event) error {
+	<-s.gate
+	return s.recordingNotifier.Notify(event)
+}
+
+// routeErrors records the errors passed to a route's OnError.
+type routeErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *routeErrors) record(_ Event, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errs = append(r.errs, err)
+}
+
+func (r *routeErrors) all() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]error(nil), r.errs...)
+}
+
+func TestMultiNotifier(t *testing.T) {
+	const alice, bob = "0xa11ce", "0xb0b"
+
+	incoming := ethereum.Transaction{Hash: "0xin", From: bob, To: ptr(alice), Value: "0x1"}
+	outgoing := ethereum.Transaction{Hash: "0xout", From: alice, To: ptr(bob), Value: "0x1"}
+
+	mined := Event{Kind: EventMined, Address: alice, Transactions: []ethereum.Transaction{incoming, outgoing}}
+	pending := Event{Kind: EventPending, Address: alice, Transactions: []ethereum.Transaction{outgoing}}
+
+	t.Run("it routes events by kind and subscription", func(tt *testing.T) {
+		everything := newRecordingNotifier()
+		pendingOnly := newRecordingNotifier()
+		incomingOnly := newRecordingNotifier()
+
+		multi, err := NewMultiNotifier(
+			Route{Name: "everything", Notifier: everything},
+			Route{Name: "pending", Notifier: pendingOnly, Kinds: []EventKind{EventPending}},
+			Route{
+				Name:          "incoming",
+				Notifier:      incomingOnly,
+				Subscriptions: []Subscription{{Address: "0xA11CE", Direction: DirectionIncoming}},
+			},
+		)
+		if err != nil {
+			tt.Fatalf("could not create notifier: %v", err)
+		}
+
+		for _, event := range []Event{mined, pending} {
+			if err := multi.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		multi.Close()
+
+		if got := everything.all(); len(got) != 2 {
+			tt.Fatalf("got %d events, want 2", len(got))
+		}
+
+		if got := pendingOnly.all(); len(got) != 1 || got[0].Kind != EventPending {
+			tt.Fatalf("got %+v, want the pending event", got)
+		}
+
+		got := incomingOnly.all()
+		if len(got) != 1 || len(got[0].Transactions) != 1 || got[0].Transactions[0].Hash != "0xin" {
+			tt.Fatalf("got %+v, want the mined event with the incoming transaction only", got)
+		}
+
+		if len(mined.Transactions) != 2 {
+			tt.Fatalf("filtering modified the notified event")
+		}
+	})
+
+	t.Run("it does not let a stuck route hold up the others", func(tt *testing.T) {
+		healthy := newRecordingNotifier()
+		stuck := &stuckNotifier{recordingNotifier: newRecordingNotifier(), gate: make(chan struct{})}
+		errs := &routeErrors{}
+
+		multi, err := NewMultiNotifier(
+			Route{Name: "stuck", Notifier: stuck, QueueSize: 1, OnError: errs.record},
+			Route{Name: "healthy", Notifier: healthy},
+		)
+		if err != nil {
+			tt.Fatalf("could not create notifier: %v", err)
+		}
+
+		const total = 5
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for range total {
+				multi.Notify(mined)
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			tt.Fatalf("Notify blocked on the stuck route")
+		}
+
+		for deadline := time.Now().Add(time.Second); len(healthy.all()) < total; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				tt.Fatalf("got %d events on the healthy route, want %d", len(healthy.all()), total)
+			}
+		}
+
+		close(stuck.gate)
+		multi.Close()
+
+		dropped := errs.all()
+		if len(dropped) == 0 || len(stuck.all())+len(dropped) != total {
+			tt.Fatalf("got %d delivered and %d dropped on the stuck route, want %d in all", len(stuck.all()), len(dropped), total)
+		}
+
+		for _, err := range dropped {
+			if !errors.Is(err, ErrQueueFull) {
+				tt.Fatalf("got: '%v', want: '%v'", err, ErrQueueFull)
+			}
+		}
+	})
+
+	t.Run("it reports notifier errors to the route", func(tt *testing.T) {
+		flaky := &flakyNotifier{recordingNotifier: newRecordingNotifier(), failures: 1}
+		errs := &routeErrors{}
+
+		multi, err := NewMultiNotifier(Route{Notifier: flaky, OnError: errs.record})
+		if err != nil {
+			tt.Fatalf("could not create notifier: %v", err)
+		}
+
+		for range 2 {
+			if err := multi.Notify(mined); err != nil {
+				tt.Fatalf("got: '%v', want no error", err)
+			}
+		}
+
+		multi.Close()
+
+		if got := errs.all(); len(got) != 1 || !errors.Is(got[0], errUnavailable) {
+			tt.Fatalf("got %v, want one '%v'", got, errUnavailable)
+		}
+
+		if got := flaky.all(); len(got) != 1 {
+			tt.Fatalf("got %d events, want 1", len(got))
+		}
+	})
+
+	t.Run("it refuses events after Close", func(tt *testing.T) {
+		multi, err := NewMultiNotifier(Route{Notifier: newRecordingNotifier()})
+		if err != nil {
+			tt.Fatalf("could not create notifier: %v", err)
+		}
+
+		multi.Close()
+
+		if err := multi.Notify(mined); !errors.Is(err, ErrNotifierClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrNotifierClosed)
+		}
+	})
+
+	t.Run("it only routes transfers to subscriptions without transaction filters", func(tt *testing.T) {
+		transfers := Event{
+			Kind:           EventMined,
+			Address:        alice,
+			TokenTransfers: []ethereum.TokenTransfer{{From: alice, To: bob, Amount: "0x1", TransactionHash: "0xtoken"}},
+			NFTTransfers:   []ethereum.NFTTransfer{{From: alice, To: bob, Amount: "0x1", TransactionHash: "0xnft"}},
+		}
+
+		if _, ok := FilterEvent(transfers, []Subscription{{Address: alice, Direction: DirectionOutgoing, MinValue: "10"}}); ok {
+			tt.Fatalf("transfers should not match a subscription with a minimum value")
+		}
+
+		if _, ok := FilterEvent(transfers, []Subscription{{Address: alice, Expression: "to == 0xb0b && value > 1e18"}}); ok {
+			tt.Fatalf("transfers should not match a subscription with an expression")
+		}
+
+		filtered, ok := FilterEvent(transfers, []Subscription{{Address: alice, Direction: DirectionOutgoing}})
+		if !ok || len(filtered.TokenTransfers) != 1 || len(filtered.NFTTransfers) != 1 {
+			tt.Fatalf("got %+v, want the transfers", filtered)
+		}
+	})
+
+	t.Run("it rejects invalid routes", func(tt *testing.T) {
+		for _, r := range []Route{
+			{Name: "no notifier"},
+			{Notifier: newRecordingNotifier(), Subscriptions: []Subscription{{Address: alice, Direction: "sideways"}}},
+			{Notifier: newRecordingNotifier(), DropPolicy: DropNone},
+		} {
+			if _, err := NewMultiNotifier(r); !errors.Is(err, ErrInvalidRoute) {
+				tt.Fatalf("(%+v) got: '%v', want: '%v'", r, err, ErrInvalidRoute)
+			}
+		}
+	})
+}
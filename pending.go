@@ -0,0 +1,421 @@
+package txnotify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+// PendingFilterClient is implemented by RPC clients that can poll the node's mempool
+// through a pending transaction filter.
+type PendingFilterClient interface {
+	NewPendingTransactionFilter() (*rpc.Response[string], error)
+	GetFilterChanges(filterID string) (*rpc.Response[[]string], error)
+}
+
+// PendingSubscriber is implemented by RPC clients that can push the hashes of new
+// pending transactions to the watcher.
+type PendingSubscriber interface {
+	SubscribePendingTransactions() (*rpc.Subscription[string], error)
+}
+
+// TransactionBatchClient is implemented by RPC clients that can fetch several transactions
+// in a single round trip.
+type TransactionBatchClient interface {
+	GetTransactionsByHash(hashes []string) ([]rpc.BatchResult[ethereum.Transaction], error)
+}
+
+// maxTxBatch bounds how many transactions are fetched in a single round trip.
+const maxTxBatch = 100
+
+// mempoolTx is a pending transaction involving a subscribed address, kept until it is mined,
+// replaced or dropped. lastSeen is the last time the node reported it as known.
+type mempoolTx struct {
+	tx        ethereum.Transaction
+	subs      []string
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// listenPending notifies subscribed addresses of transactions entering the mempool. Hashes
+// are pushed by the node when it supports newPendingTransactions subscriptions, otherwise
+// a pending transaction filter is polled every pollInterval.
+func (watcher *Watcher) listenPending(ctx context.Context) {
+	subscriber, ok := watcher.rpcClient.(PendingSubscriber)
+	if !ok {
+		watcher.pollPending(ctx)
+		return
+	}
+
+	for {
+		sub, err := subscriber.SubscribePendingTransactions()
+
+		var rpcErr rpc.JSONRPCError
+
+		switch {
+		case errors.Is(err, rpc.ErrSubscriptionsNotSupported), errors.As(err, &rpcErr):
+			watcher.logger.Info("pending transaction subscriptions unavailable, polling instead", "error", err)
+			watcher.pollPending(ctx)
+
+			return
+
+		case err != nil:
+			watcher.logger.Error("could not subscribe to pending transactions", "error", err)
+
+		default:
+			watcher.logger.Info("subscribed to pending transactions", "subscription", sub.ID)
+			watcher.consumePending(ctx, sub)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watcher.pollInterval):
+		}
+	}
+}
+
+// consumePending handles pending transaction hashes until the subscription ends or ctx is done.
+// The hashes already buffered are looked up together, and those the node pushed while the
+// buffer was full, which are lost, are logged.
+func (watcher *Watcher) consumePending(ctx context.Context, sub *rpc.Subscription[string]) {
+	var dropped uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := sub.Unsubscribe(); err != nil {
+				watcher.logger.Debug("could not unsubscribe from pending transactions", "error", err)
+			}
+
+			return
+
+		case hash, ok := <-sub.C():
+			if !ok {
+				watcher.logger.Warn("pending transactions subscription ended", "error", sub.Err())
+				return
+			}
+
+			watcher.handlePendingHashes(drainPending(sub, hash))
+
+			if total := sub.Dropped(); total > dropped {
+				watcher.logger.Warn(
+					"pending transaction hashes dropped, the watcher is falling behind",
+					"subscription", sub.ID,
+					"dropped", total-dropped,
+					"total", total,
+				)
+
+				dropped = total
+			}
+		}
+	}
+}
+
+// drainPending returns first along with the hashes already buffered by the subscription, up
+// to maxTxBatch.
+func drainPending(sub *rpc.Subscription[string], first string) []string {
+	hashes := []string{first}
+
+	for len(hashes) < maxTxBatch {
+		select {
+		case hash, ok := <-sub.C():
+			if !ok {
+				return hashes
+			}
+
+			hashes = append(hashes, hash)
+
+		default:
+			return hashes
+		}
+	}
+
+	return hashes
+}
+
+// pollPending polls a pending transaction filter every pollInterval.
+func (watcher *Watcher) pollPending(ctx context.Context) {
+	client, ok := watcher.rpcClient.(PendingFilterClient)
+	if !ok {
+		watcher.logger.Warn("rpc client cannot report pending transactions")
+		return
+	}
+
+	filterID := ""
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-time.After(watcher.pollInterval):
+			filterID = watcher.checkPending(client, filterID)
+		}
+	}
+}
+
+// checkPending handles the hashes collected by the filter since the last call, installing
+// the filter first if needed. It returns the filter ID to use on the next call, which is
+// empty if the filter has to be (re)installed.
+func (watcher *Watcher) checkPending(client PendingFilterClient, filterID string) string {
+	if filterID == "" {
+		resp, err := client.NewPendingTransactionFilter()
+		if err != nil {
+			watcher.logger.Error("could not install pending transaction filter", "error", err)
+			return ""
+		}
+
+		filterID = resp.Result
+	}
+
+	changes, err := client.GetFilterChanges(filterID)
+	if err != nil {
+		// nodes uninstall filters that are not polled often enough.
+		watcher.logger.Warn("could not get pending transactions, reinstalling filter", "filterID", filterID, "error", err)
+		return ""
+	}
+
+	watcher.handlePendingHashes(changes.Result)
+
+	return filterID
+}
+
+// handlePendingHashes fetches pending transactions and notifies the subscribed addresses
+// involved in them. Transactions already known, or no longer pending, are skipped.
+func (watcher *Watcher) handlePendingHashes(hashes []string) {
+	state := watcher.copyState()
+	if len(state.subs) == 0 {
+		return
+	}
+
+	watcher.mu.Lock()
+
+	unknown := make([]string, 0, len(hashes))
+
+	for _, hash := range hashes {
+		if _, known := watcher.mempool[hash]; !known && !slices.Contains(unknown, hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+
+	watcher.mu.Unlock()
+
+	for i, result := range watcher.getTransactions(unknown) {
+		if result.Err != nil {
+			watcher.logger.Debug("could not get pending transaction", "hash", unknown[i], "error", result.Err)
+			continue
+		}
+
+		tx := result.Result
+		if tx.Hash == "" || tx.BlockNumber != nil {
+			continue
+		}
+
+		watcher.addPending(tx, state.subs)
+	}
+}
+
+// getTransactions fetches transactions by hash, maxTxBatch at a time when the RPC client is a
+// TransactionBatchClient and one by one otherwise. Results are in the same order as hashes.
+func (watcher *Watcher) getTransactions(hashes []string) []rpc.BatchResult[ethereum.Transaction] {
+	results := make([]rpc.BatchResult[ethereum.Transaction], 0, len(hashes))
+	client, batches := watcher.rpcClient.(TransactionBatchClient)
+
+	for chunk := range slices.Chunk(hashes, maxTxBatch) {
+		if batches {
+			batch, err := client.GetTransactionsByHash(chunk)
+			if err == nil {
+				results = append(results, batch...)
+				continue
+			}
+
+			// some nodes reject batches, fall back to one call per transaction.
+			watcher.logger.Debug("could not get transactions in a batch", "count", len(chunk), "error", err)
+		}
+
+		for _, hash := range chunk {
+			resp, err := watcher.rpcClient.GetTransactionByHash(hash)
+			if err != nil {
+				results = append(results, rpc.BatchResult[ethereum.Transaction]{Err: err})
+				continue
+			}
+
+			results = append(results, rpc.BatchResult[ethereum.Transaction]{Result: resp.Result})
+		}
+	}
+
+	return results
+}
+
+// addPending records a pending transaction and sends an EventPending notification to
+// each subscribed address it involves whose subscriptions match it.
+func (watcher *Watcher) addPending(tx ethereum.Transaction, subs []string) {
+	txxMap := groupByAddress([]ethereum.Transaction{tx})
+
+	involved := make([]string, 0, len(txxMap))
+
+	for _, addr := range subs {
+		if len(watcher.filterTxs(addr, txxMap[addr])) > 0 {
+			involved = append(involved, addr)
+		}
+	}
+
+	if len(involved) == 0 {
+		return
+	}
+
+	watcher.mu.Lock()
+
+	if _, known := watcher.mempool[tx.Hash]; known {
+		watcher.mu.Unlock()
+		return
+	}
+
+	if watcher.mempool == nil {
+		watcher.mempool = make(map[string]mempoolTx)
+	}
+
+	now := time.Now()
+	watcher.mempool[tx.Hash] = mempoolTx{tx: tx, subs: involved, firstSeen: now, lastSeen: now}
+	watcher.mu.Unlock()
+
+	watcher.logger.Info("pending transaction", "hash", tx.Hash)
+	watcher.notifyMempool(EventPending, mempoolTx{tx: tx, subs: involved})
+}
+
+// settleMempool forgets the pending transactions included in a processed block. Pending
+// transactions whose sender and nonce were used by a different mined transaction (a speed-up
+// or cancellation) are notified as EventReplaced, with ReplacedBy set to the mined hash.
+func (watcher *Watcher) settleMempool(txList []ethereum.Transaction) {
+	watcher.mu.Lock()
+
+	if len(watcher.mempool) == 0 {
+		watcher.mu.Unlock()
+		return
+	}
+
+	mined := make(map[string]string, len(txList))
+
+	for _, tx := range txList {
+		delete(watcher.mempool, tx.Hash)
+		mined[senderNonce(tx)] = tx.Hash
+	}
+
+	var replaced []mempoolTx
+
+	for hash, entry := range watcher.mempool {
+		replacement, ok := mined[senderNonce(entry.tx)]
+		if !ok {
+			continue
+		}
+
+		delete(watcher.mempool, hash)
+
+		entry.tx.ReplacedBy = &replacement
+		replaced = append(replaced, entry)
+	}
+
+	watcher.mu.Unlock()
+
+	for _, entry := range replaced {
+		watcher.logger.Info("pending transaction replaced", "hash", entry.tx.Hash, "replacedBy", *entry.tx.ReplacedBy)
+		watcher.notifyMempool(EventReplaced, entry)
+	}
+}
+
+// senderNonce identifies the slot a transaction takes in its sender's sequence.
+func senderNonce(tx ethereum.Transaction) string {
+	return NormalizeAddress(tx.From) + "/" + strings.ToLower(tx.Nonce)
+}
+
+// sweepMempool checks for dropped pending transactions every pollInterval.
+func (watcher *Watcher) sweepMempool(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-time.After(watcher.pollInterval):
+			watcher.checkDropped(time.Now())
+		}
+	}
+}
+
+// checkDropped asks the node about every tracked pending transaction, in batches when the RPC
+// client supports it. Those it has not known for at least dropAfter are forgotten and notified
+// as EventDropped. Failing RPC calls are not taken as the transaction being gone.
+func (watcher *Watcher) checkDropped(now time.Time) {
+	watcher.mu.Lock()
+
+	entries := make([]mempoolTx, 0, len(watcher.mempool))
+	for _, entry := range watcher.mempool {
+		entries = append(entries, entry)
+	}
+
+	watcher.mu.Unlock()
+
+	hashes := make([]string, len(entries))
+	for i, entry := range entries {
+		hashes[i] = entry.tx.Hash
+	}
+
+	for i, result := range watcher.getTransactions(hashes) {
+		entry := entries[i]
+
+		if result.Err != nil {
+			watcher.logger.Debug("could not get pending transaction", "hash", entry.tx.Hash, "error", result.Err)
+			continue
+		}
+
+		// mined transactions are known too, and are settled once their block is processed.
+		if result.Result.Hash != "" {
+			watcher.touchPending(entry.tx.Hash, now)
+			continue
+		}
+
+		if now.Sub(entry.lastSeen) < watcher.dropAfter || !watcher.forgetPending(entry.tx.Hash) {
+			continue
+		}
+
+		watcher.logger.Info("pending transaction dropped", "hash", entry.tx.Hash, "lastSeen", entry.lastSeen)
+		watcher.notifyMempool(EventDropped, entry)
+	}
+}
+
+// touchPending records that the node still knows a pending transaction.
+func (watcher *Watcher) touchPending(hash string, now time.Time) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if entry, ok := watcher.mempool[hash]; ok {
+		entry.lastSeen = now
+		watcher.mempool[hash] = entry
+	}
+}
+
+// forgetPending stops tracking a pending transaction, reporting whether it was still tracked.
+func (watcher *Watcher) forgetPending(hash string) bool {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if _, ok := watcher.mempool[hash]; !ok {
+		return false
+	}
+
+	delete(watcher.mempool, hash)
+
+	return true
+}
+
+// notifyMempool sends a notification about a tracked pending transaction to the addresses it involves.
+func (watcher *Watcher) notifyMempool(kind EventKind, entry mempoolTx) {
+	for _, addr := range entry.subs {
+		watcher.notify(Event{Kind: kind, Address: addr, Transactions: []ethereum.Transaction{entry.tx}})
+	}
+}
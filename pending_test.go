@@ -0,0 +1,231 @@
+package txnotify
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+func TestWatcherPending(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+		carol = "0xca201"
+	)
+
+	setup := func(tt *testing.T) (*mockChain, *recordingNotifier, *Watcher) {
+		tt.Helper()
+
+		chain := newMockChain()
+		chain.addBlock("0x10", "0xh10", "0xh9")
+		chain.setHead("0x10")
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		return chain, notifier, watcher
+	}
+
+	t.Run("it notifies pending transactions before they are mined", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		filterID := watcher.checkPending(chain, "")
+		if filterID != "0xfilter" {
+			tt.Fatalf("got filter '%s', want '0xfilter'", filterID)
+		}
+
+		tx := ethereum.Transaction{Hash: "0xtxa", From: carol, To: ptr(alice)}
+		chain.addPending(tx)
+		watcher.checkPending(chain, filterID)
+
+		notifier.waitFor(tt, EventPending, alice, "0xtxa")
+
+		chain.addBlock("0x11", "0xh11", "0xh10", tx)
+		chain.setHead("0x11")
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		notifier.waitFor(tt, EventMined, alice, "0xtxa")
+
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+
+		if len(watcher.mempool) != 0 {
+			tt.Fatalf("mined transactions should be removed from the mempool, got %d left", len(watcher.mempool))
+		}
+	})
+
+	t.Run("it ignores transactions not involving subscribed addresses", func(tt *testing.T) {
+		chain, _, watcher := setup(tt)
+
+		filterID := watcher.checkPending(chain, "")
+
+		chain.addPending(ethereum.Transaction{Hash: "0xtxb", From: carol, To: ptr(bob)})
+		watcher.checkPending(chain, filterID)
+
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+
+		if len(watcher.mempool) != 0 {
+			tt.Fatalf("unrelated transactions should not be tracked")
+		}
+	})
+
+	t.Run("it skips transactions that are already mined", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		tx := ethereum.Transaction{Hash: "0xtxc", From: alice, To: ptr(bob)}
+		chain.addBlock("0x11", "0xh11", "0xh10", tx)
+		watcher.handlePendingHashes([]string{tx.Hash})
+
+		if notifier.has(recordedEvent{kind: EventPending, address: alice, hash: tx.Hash}) {
+			tt.Fatalf("mined transactions should not be notified as pending")
+		}
+	})
+
+	t.Run("it looks up pending transactions in batches", func(tt *testing.T) {
+		chain := &batchingChain{mockChain: newMockChain()}
+		chain.addBlock("0x10", "0xh10", "0xh9")
+		chain.setHead("0x10")
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		filterID := watcher.checkPending(chain, "")
+
+		hashes := []string{"0xtx1", "0xtx2", "0xtx3"}
+		for _, hash := range hashes {
+			chain.addPending(ethereum.Transaction{Hash: hash, From: carol, To: ptr(alice)})
+		}
+
+		watcher.checkPending(chain, filterID)
+
+		for _, hash := range hashes {
+			notifier.waitFor(tt, EventPending, alice, hash)
+		}
+
+		if got := chain.batchCount(); got != 1 {
+			tt.Fatalf("got %d batches, want 1", got)
+		}
+	})
+
+	t.Run("it reinstalls a filter the node dropped", func(tt *testing.T) {
+		chain, _, watcher := setup(tt)
+
+		if filterID := watcher.checkPending(chain, "0xexpired"); filterID != "" {
+			tt.Fatalf("got filter '%s', want it reset", filterID)
+		}
+
+		if filterID := watcher.checkPending(chain, ""); filterID != "0xfilter" {
+			tt.Fatalf("got filter '%s', want '0xfilter'", filterID)
+		}
+	})
+	t.Run("it notifies pending transactions replaced by another with the same nonce", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		filterID := watcher.checkPending(chain, "")
+
+		original := ethereum.Transaction{Hash: "0xtxd", From: carol, To: ptr(alice), Nonce: "0x7"}
+		chain.addPending(original)
+		watcher.checkPending(chain, filterID)
+		notifier.waitFor(tt, EventPending, alice, original.Hash)
+
+		// the sender cancels the payment by sending to itself with the same nonce.
+		cancellation := ethereum.Transaction{Hash: "0xtxe", From: carol, To: ptr(carol), Nonce: "0x7"}
+		chain.addBlock("0x11", "0xh11", "0xh10", cancellation)
+		chain.setHead("0x11")
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		notifier.waitFor(tt, EventReplaced, alice, original.Hash)
+
+		replaced := notifier.tx(original.Hash)
+		if replaced.ReplacedBy == nil || *replaced.ReplacedBy != cancellation.Hash {
+			tt.Fatalf("got ReplacedBy %v, want %s", replaced.ReplacedBy, cancellation.Hash)
+		}
+	})
+
+	t.Run("it notifies pending transactions the node dropped", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+		watcher.dropAfter = time.Minute
+
+		filterID := watcher.checkPending(chain, "")
+
+		tx := ethereum.Transaction{Hash: "0xtxf", From: alice, To: ptr(bob), Nonce: "0x1"}
+		chain.addPending(tx)
+		watcher.checkPending(chain, filterID)
+		notifier.waitFor(tt, EventPending, alice, tx.Hash)
+
+		now := time.Now()
+
+		// still known by the node.
+		watcher.checkDropped(now.Add(2 * time.Minute))
+
+		chain.evict(tx.Hash)
+
+		watcher.checkDropped(now.Add(2*time.Minute + time.Second))
+
+		if notifier.has(recordedEvent{kind: EventDropped, address: alice, hash: tx.Hash}) {
+			tt.Fatalf("transaction should not be dropped before DropAfter has elapsed")
+		}
+
+		watcher.checkDropped(now.Add(4 * time.Minute))
+		notifier.waitFor(tt, EventDropped, alice, tx.Hash)
+
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+
+		if len(watcher.mempool) != 0 {
+			tt.Fatalf("dropped transactions should no longer be tracked")
+		}
+	})
+}
+
+// batchingChain is a mockChain that also fetches transactions in batches, counting them.
+type batchingChain struct {
+	*mockChain
+
+	batchMu sync.Mutex
+	batches int
+}
+
+func (b *batchingChain) GetTransactionsByHash(hashes []string) ([]rpc.BatchResult[ethereum.Transaction], error) {
+	b.batchMu.Lock()
+	b.batches++
+	b.batchMu.Unlock()
+
+	results := make([]rpc.BatchResult[ethereum.Transaction], len(hashes))
+
+	for i, hash := range hashes {
+		resp, err := b.GetTransactionByHash(hash)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Result = resp.Result
+	}
+
+	return results, nil
+}
+
+func (b *batchingChain) batchCount() int {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	return b.batches
+}
@@ -0,0 +1,98 @@
+package txnotify
+
+import (
+	"fmt"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// enrichBlock attaches the receipts, created contract addresses and token/NFT transfers
+// of a freshly fetched block.
+func (watcher *Watcher) enrichBlock(blockNum string, block *ethereum.Block, subs []string) error {
+	if err := watcher.attachReceipts(blockNum, block, subs); err != nil {
+		return fmt.Errorf("could not get receipts: %w", err)
+	}
+
+	watcher.attachContractAddresses(block)
+
+	if err := watcher.attachTransfers(blockNum, block); err != nil {
+		return fmt.Errorf("could not get transfers: %w", err)
+	}
+
+	return nil
+}
+
+// attachReceipts sets the receipt of every transaction in block. Receipts are fetched for the
+// whole block at once; if that fails (eth_getBlockReceipts is not supported by every node) they
+// are fetched one by one, but only for transactions involving subs or a subscribed address. The
+// block is cached with its receipts, so subscribed addresses are included even when subs is only
+// a few addresses passed to Backfill.
+func (watcher *Watcher) attachReceipts(blockNum string, block *ethereum.Block, subs []string) error {
+	if len(block.Transactions) == 0 {
+		return nil
+	}
+
+	receipts := make(map[string]ethereum.Receipt, len(block.Transactions))
+
+	resp, err := watcher.rpcClient.GetBlockReceipts(blockNum)
+	if err != nil {
+		watcher.logger.Debug("could not get block receipts, falling back", "blockNum", blockNum, "error", err)
+	} else {
+		for _, receipt := range resp.Result {
+			receipts[receipt.TransactionHash] = receipt
+		}
+	}
+
+	subscribed := make(map[string]struct{}, len(subs))
+	for _, addr := range append(watcher.copyState().subs, subs...) {
+		subscribed[addr] = struct{}{}
+	}
+
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+
+		receipt, found := receipts[tx.Hash]
+		if !found {
+			if !involves(*tx, subscribed) {
+				continue
+			}
+
+			receipt, err = watcher.fetchReceipt(tx.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		tx.Receipt = &receipt
+	}
+
+	return nil
+}
+
+func (watcher *Watcher) fetchReceipt(hash string) (ethereum.Receipt, error) {
+	resp, err := watcher.rpcClient.GetTransactionReceipt(hash)
+	if err != nil {
+		return ethereum.Receipt{}, fmt.Errorf("could not get receipt for %s: %w", hash, err)
+	}
+
+	if resp.Result.TransactionHash == "" {
+		return ethereum.Receipt{}, fmt.Errorf("receipt for %s not found", hash)
+	}
+
+	return resp.Result, nil
+}
+
+// involves reports whether tx was sent from or to one of the given (normalized) addresses.
+func involves(tx ethereum.Transaction, addresses map[string]struct{}) bool {
+	if _, ok := addresses[NormalizeAddress(tx.From)]; ok {
+		return true
+	}
+
+	if tx.To == nil {
+		return false
+	}
+
+	_, ok := addresses[NormalizeAddress(*tx.To)]
+
+	return ok
+}
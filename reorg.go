@@ -0,0 +1,181 @@
+package txnotify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+// maxReorgDepth bounds how many blocks rollback will walk back looking for a common ancestor.
+const maxReorgDepth = 128
+
+var ErrReorgTooDeep = errors.New("reorganization exceeds maximum depth")
+
+// detectReorg reports whether block does not build on the cached block preceding it.
+// If the predecessor is not cached there is nothing to compare against, so no reorg is reported.
+func (watcher *Watcher) detectReorg(blockNum string, block ethereum.Block) bool {
+	num, err := strToHex(blockNum)
+	if err != nil || num == 0 {
+		return false
+	}
+
+	parent, err := watcher.cache.GetBlock(numToStr(num - 1))
+	if err != nil {
+		return false
+	}
+
+	return block.ParentHash != "" && block.ParentHash != parent.Hash
+}
+
+type orphanedBlock struct {
+	num       string
+	block     ethereum.Block
+	canonical ethereum.Block
+	processed bool
+}
+
+// rollback walks back from blockNum (whose parent hash did not match the cached predecessor)
+// until it finds the common ancestor with the canonical chain. Orphaned blocks are replaced
+// in the cache by their canonical counterparts, the watcher is rewound so the canonical blocks
+// are processed again, and reverted notifications are sent for dropped transactions that had
+// already been notified.
+func (watcher *Watcher) rollback(blockNum string, block ethereum.Block, subs []string) error {
+	orphans, err := watcher.findOrphans(blockNum, block, subs)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	// transactions that made it into the canonical chain were not dropped.
+	included := make(map[string]struct{})
+	for _, tx := range block.Transactions {
+		included[tx.Hash] = struct{}{}
+	}
+
+	var dropped ethereum.Block
+
+	for _, orphan := range orphans {
+		for _, tx := range orphan.canonical.Transactions {
+			included[tx.Hash] = struct{}{}
+		}
+
+		if err := watcher.cache.RemoveBlock(orphan.num); err != nil {
+			return fmt.Errorf("could not evict block %s: %w", orphan.num, err)
+		}
+
+		if err := watcher.cache.AddBlock(orphan.num, orphan.canonical); err != nil {
+			return fmt.Errorf("could not store block %s: %w", orphan.num, err)
+		}
+
+		// blocks still waiting for confirmations were only notified if unconfirmed
+		// notifications are enabled.
+		pending := watcher.dropPending(orphan.num)
+		if orphan.processed && (!pending || watcher.notifyUnconfirmed) {
+			dropped.Transactions = append(dropped.Transactions, orphan.block.Transactions...)
+			dropped.TokenTransfers = append(dropped.TokenTransfers, orphan.block.TokenTransfers...)
+			dropped.NFTTransfers = append(dropped.NFTTransfers, orphan.block.NFTTransfers...)
+		}
+	}
+
+	// orphans are ordered from newest to oldest, resume from the oldest.
+	resumeFrom := orphans[len(orphans)-1].num
+
+	watcher.mu.Lock()
+	watcher.currentBlock = resumeFrom
+	watcher.mu.Unlock()
+
+	if resumeNum, err := strToHex(resumeFrom); err == nil {
+		watcher.rewindCheckpoint(numToStr(resumeNum - 1))
+	}
+
+	watcher.logger.Info(
+		"rolled back orphaned blocks",
+		"depth", len(orphans),
+		"resumeFrom", resumeFrom,
+	)
+
+	watcher.notifyContents(EventReverted, withoutIncluded(dropped, included), subs, nil)
+
+	return nil
+}
+
+// withoutIncluded returns the contents of dropped that belong to transactions not in included.
+func withoutIncluded(dropped ethereum.Block, included map[string]struct{}) ethereum.Block {
+	isDropped := func(hash string) bool {
+		_, ok := included[hash]
+		return !ok
+	}
+
+	reverted := ethereum.Block{}
+
+	for _, tx := range dropped.Transactions {
+		if isDropped(tx.Hash) {
+			reverted.Transactions = append(reverted.Transactions, tx)
+		}
+	}
+
+	for _, transfer := range dropped.TokenTransfers {
+		if isDropped(transfer.TransactionHash) {
+			reverted.TokenTransfers = append(reverted.TokenTransfers, transfer)
+		}
+	}
+
+	for _, transfer := range dropped.NFTTransfers {
+		if isDropped(transfer.TransactionHash) {
+			reverted.NFTTransfers = append(reverted.NFTTransfers, transfer)
+		}
+	}
+
+	return reverted
+}
+
+// findOrphans follows parent hashes back from block, fetching the canonical chain, until it
+// reaches a cached block that the canonical chain builds on. Orphans are returned newest first.
+func (watcher *Watcher) findOrphans(blockNum string, block ethereum.Block, subs []string) ([]orphanedBlock, error) {
+	num, err := strToHex(blockNum)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse block number: %w", err)
+	}
+
+	orphans := make([]orphanedBlock, 0)
+	child := block
+
+	for n := num - 1; n >= 0; n-- {
+		if len(orphans) == maxReorgDepth {
+			return nil, ErrReorgTooDeep
+		}
+
+		ancestorNum := numToStr(n)
+
+		cached, err := watcher.cache.GetBlock(ancestorNum)
+		if err != nil || cached.Hash == child.ParentHash {
+			break
+		}
+
+		canonical, err := watcher.getBlockByNumber(ancestorNum)
+		if err != nil {
+			return nil, fmt.Errorf("could not get canonical block %s: %w", ancestorNum, err)
+		}
+
+		if err := watcher.enrichBlock(ancestorNum, &canonical, subs); err != nil {
+			return nil, fmt.Errorf("could not enrich block %s: %w", ancestorNum, err)
+		}
+
+		processed, err := watcher.cache.GetBlockProcessed(ancestorNum)
+
+		orphans = append(orphans, orphanedBlock{
+			num:       ancestorNum,
+			block:     cached,
+			canonical: canonical,
+			processed: err == nil && processed,
+		})
+
+		child = canonical
+	}
+
+	return orphans, nil
+}
@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrEmptyBatch = errors.New("batch has no requests")
+
+// BatchResult is the outcome of a single call in a batch. Err holds the call's
+// JSONRPCError, if any, or an error decoding its result.
+type BatchResult[T any] struct {
+	Result T
+	Err    error
+}
+
+// DoBatch sends all requests in a single round trip (one POST, or one websocket message) and
+// returns their responses in the same order as the requests, regardless of the order the server
+// answered in. Request IDs are assigned by DoBatch. Per-call failures are reported in each
+// response's Error field.
+func (client *Client) DoBatch(requests []Request) ([]Response[json.RawMessage], error) {
+	if len(requests) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	if client.IsWebsocket() {
+		conn, err := client.wsConn()
+		if err != nil {
+			return nil, err
+		}
+
+		return conn.call(requests, true)
+	}
+
+	batch := make([]Request, len(requests))
+	for i, req := range requests {
+		req.JSONRPC = "2.0"
+		// IDs start at 1, since 0 would be dropped by omitempty.
+		req.ID = i + 1
+		batch[i] = req
+	}
+
+	reqBody := &bytes.Buffer{}
+
+	if err := json.NewEncoder(reqBody).Encode(batch); err != nil {
+		return nil, fmt.Errorf("could not encode body: %w", err)
+	}
+
+	resp, err := client.httpClient.Post(
+		client.endpoint,
+		"application/json",
+		reqBody,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("post failed: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	raw := json.RawMessage{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("could not decode response body: %w", err)
+	}
+
+	// servers reply with a single response object if the batch as a whole was rejected.
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		single := Response[json.RawMessage]{}
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, fmt.Errorf("could not decode response body: %w", err)
+		}
+
+		if single.Error.Code != 0 {
+			return nil, single.Error
+		}
+
+		return nil, fmt.Errorf("expected a batch response, got a single response with id %d", single.ID)
+	}
+
+	respBody := make([]Response[json.RawMessage], 0, len(batch))
+
+	if err := json.Unmarshal(raw, &respBody); err != nil {
+		return nil, fmt.Errorf("could not decode response body: %w", err)
+	}
+
+	byID := make(map[int]Response[json.RawMessage], len(respBody))
+	for _, item := range respBody {
+		byID[item.ID] = item
+	}
+
+	responses := make([]Response[json.RawMessage], len(batch))
+
+	for i, req := range batch {
+		item, found := byID[req.ID]
+		if !found {
+			return nil, fmt.Errorf("missing response for id %d (%s)", req.ID, req.Method)
+		}
+
+		responses[i] = item
+	}
+
+	return responses, nil
+}
+
+// Batch calls method once per entry in paramsList, in a single round trip, decoding
+// each result as T. Results are returned in the same order as paramsList.
+func Batch[T any](client *Client, method string, paramsList [][]any) ([]BatchResult[T], error) {
+	requests := make([]Request, len(paramsList))
+	for i, params := range paramsList {
+		requests[i] = Request{Method: method, Params: params}
+	}
+
+	responses, err := client.DoBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult[T], len(responses))
+
+	for i, resp := range responses {
+		if resp.Error.Code != 0 {
+			results[i].Err = resp.Error
+			continue
+		}
+
+		if err := json.Unmarshal(resp.Result, &results[i].Result); err != nil {
+			results[i].Err = fmt.Errorf("could not decode result: %w", err)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	const missingBlock = "0xdead"
+
+	wantErr := JSONRPCError{Code: -32000, Message: "block not found"}
+
+	// the server answers in reverse order to check responses are correlated by ID.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var batch []Request
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]map[string]any, 0, len(batch))
+
+		for i := len(batch) - 1; i >= 0; i-- {
+			item := batch[i]
+			resp := map[string]any{"jsonrpc": "2.0", "id": item.ID}
+
+			if item.Params[0] == missingBlock {
+				resp["error"] = wantErr
+			} else {
+				resp["result"] = map[string]any{"hash": fmt.Sprintf("hash-%v", item.Params[0])}
+			}
+
+			responses = append(responses, resp)
+		}
+
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+
+	client := mustMakeClient(t, server.URL)
+
+	results, err := client.GetBlocksByNumber([]string{"0x1", missingBlock, "0x3"})
+	if err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+
+	if n := len(results); n != 3 {
+		t.Fatalf("got %d results, want 3", n)
+	}
+
+	if got := results[0].Result.Hash; got != "hash-0x1" {
+		t.Fatalf("(results[0]) got %s, want hash-0x1", got)
+	}
+
+	if !errors.Is(results[1].Err, wantErr) {
+		t.Fatalf("(results[1]) got: '%v', want: '%v'", results[1].Err, wantErr)
+	}
+
+	if got := results[2].Result.Hash; got != "hash-0x3" {
+		t.Fatalf("(results[2]) got %s, want hash-0x3", got)
+	}
+}
+
+func TestBatchRejected(t *testing.T) {
+	wantErr := JSONRPCError{Code: -32600, Message: "batch too large"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": nil, "error": wantErr})
+	}))
+	defer server.Close()
+
+	client := mustMakeClient(t, server.URL)
+
+	_, err := Batch[string](client, getCurrentBlockMethod, [][]any{{}, {}})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got: '%v', want: '%v'", err, wantErr)
+	}
+}
@@ -4,7 +4,17 @@ import "github.com/aalbacetef/txnotify/ethereum"
 
 const (
 	getCurrentBlockMethod    = "eth_blockNumber"
+	chainIDMethod            = "eth_chainId"
 	getBlockByNumberEndpoint = "eth_getBlockByNumber"
+	getTxReceiptMethod       = "eth_getTransactionReceipt"
+	getBlockReceiptsMethod   = "eth_getBlockReceipts"
+	getLogsMethod            = "eth_getLogs"
+	getTxByHashMethod        = "eth_getTransactionByHash"
+	newPendingFilterMethod   = "eth_newPendingTransactionFilter"
+	getFilterChangesMethod   = "eth_getFilterChanges"
+	uninstallFilterMethod    = "eth_uninstallFilter"
+	newHeadsSubscription     = "newHeads"
+	newPendingSubscription   = "newPendingTransactions"
 )
 
 func (client *Client) GetCurrentBlockNumber() (*Response[string], error) {
@@ -13,6 +23,11 @@ func (client *Client) GetCurrentBlockNumber() (*Response[string], error) {
 	return Do[string](client, endpoint, []any{})
 }
 
+// ChainID returns the id of the node's chain as a hex-string (e.g: "0x1" for Ethereum mainnet).
+func (client *Client) ChainID() (*Response[string], error) {
+	return Do[string](client, chainIDMethod, []any{})
+}
+
 // GetBlockByNumber will return block information (hash and transactions) given
 // the block's number as a hex-string.
 func (client *Client) GetBlockByNumber(blockNum string) (*Response[ethereum.Block], error) {
@@ -27,3 +42,91 @@ func (client *Client) GetBlockByNumber(blockNum string) (*Response[ethereum.Bloc
 
 	return Do[ethereum.Block](client, endpoint, params)
 }
+
+// GetTransactionReceipt returns the receipt of a mined transaction given its hash.
+// The result is empty (zero TransactionHash) if the transaction is unknown or pending.
+func (client *Client) GetTransactionReceipt(hash string) (*Response[ethereum.Receipt], error) {
+	return Do[ethereum.Receipt](client, getTxReceiptMethod, []any{hash})
+}
+
+// GetTransactionByHash returns a transaction given its hash, whether it is pending or mined.
+// The result is empty (zero Hash) if the node does not know the transaction.
+func (client *Client) GetTransactionByHash(hash string) (*Response[ethereum.Transaction], error) {
+	return Do[ethereum.Transaction](client, getTxByHashMethod, []any{hash})
+}
+
+// GetTransactionsByHash fetches several transactions in a single round trip. Results are in
+// the same order as hashes, and empty (zero Hash) for the transactions the node does not know.
+func (client *Client) GetTransactionsByHash(hashes []string) ([]BatchResult[ethereum.Transaction], error) {
+	paramsList := make([][]any, len(hashes))
+	for i, hash := range hashes {
+		paramsList[i] = []any{hash}
+	}
+
+	return Batch[ethereum.Transaction](client, getTxByHashMethod, paramsList)
+}
+
+// GetBlockReceipts returns the receipts of every transaction in a block, given the
+// block's number as a hex-string.
+func (client *Client) GetBlockReceipts(blockNum string) (*Response[[]ethereum.Receipt], error) {
+	return Do[[]ethereum.Receipt](client, getBlockReceiptsMethod, []any{blockNum})
+}
+
+// LogFilter selects logs for eth_getLogs. BlockHash is exclusive with FromBlock/ToBlock.
+// Each entry of Topics matches one topic position and is either nil (any), a topic, or a
+// []string of alternatives.
+type LogFilter struct {
+	FromBlock string   `json:"fromBlock,omitempty"`
+	ToBlock   string   `json:"toBlock,omitempty"`
+	BlockHash string   `json:"blockHash,omitempty"`
+	Address   []string `json:"address,omitempty"`
+	Topics    []any    `json:"topics,omitempty"`
+}
+
+// GetLogs returns the logs matching filter.
+func (client *Client) GetLogs(filter LogFilter) (*Response[[]ethereum.Log], error) {
+	return Do[[]ethereum.Log](client, getLogsMethod, []any{filter})
+}
+
+// GetBlocksByNumber fetches several blocks (hash and transactions) in a single round trip.
+// Results are in the same order as blockNums.
+func (client *Client) GetBlocksByNumber(blockNums []string) ([]BatchResult[ethereum.Block], error) {
+	const getFullBlock = true
+
+	paramsList := make([][]any, len(blockNums))
+	for i, blockNum := range blockNums {
+		paramsList[i] = []any{blockNum, getFullBlock}
+	}
+
+	return Batch[ethereum.Block](client, getBlockByNumberEndpoint, paramsList)
+}
+
+// SubscribeNewHeads subscribes to the headers of new blocks as they are added to the chain.
+// It requires a websocket endpoint, otherwise ErrSubscriptionsNotSupported is returned.
+func (client *Client) SubscribeNewHeads() (*Subscription[ethereum.Header], error) {
+	return Subscribe[ethereum.Header](client, newHeadsSubscription)
+}
+
+// NewPendingTransactionFilter installs a filter on the node that collects the hashes of
+// transactions entering its mempool. It returns the filter ID to pass to GetFilterChanges.
+func (client *Client) NewPendingTransactionFilter() (*Response[string], error) {
+	return Do[string](client, newPendingFilterMethod, []any{})
+}
+
+// GetFilterChanges returns the transaction hashes collected by a pending transaction filter
+// since the previous call. Nodes uninstall filters that are not polled for a while, after which
+// an error is returned and a new filter must be installed.
+func (client *Client) GetFilterChanges(filterID string) (*Response[[]string], error) {
+	return Do[[]string](client, getFilterChangesMethod, []any{filterID})
+}
+
+// UninstallFilter removes a filter installed on the node.
+func (client *Client) UninstallFilter(filterID string) (*Response[bool], error) {
+	return Do[bool](client, uninstallFilterMethod, []any{filterID})
+}
+
+// SubscribePendingTransactions subscribes to the hashes of transactions as they enter the node's mempool.
+// It requires a websocket endpoint, otherwise ErrSubscriptionsNotSupported is returned.
+func (client *Client) SubscribePendingTransactions() (*Subscription[string], error) {
+	return Subscribe[string](client, newPendingSubscription)
+}
@@ -6,12 +6,21 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Client is a JSON-RPC client. Endpoints with an http(s):// scheme are called with one POST
+// per request, ws(s):// endpoints share a single websocket which also carries subscriptions.
 type Client struct {
-	httpClient *http.Client
-	endpoint   string
+	httpClient   *http.Client
+	endpoint     string
+	timeout      time.Duration
+	pingInterval time.Duration
+
+	wsMu sync.Mutex
+	ws   *wsConn
 }
 
 func (client *Client) generateID() int {
@@ -26,9 +35,16 @@ func (client *Client) generateID() int {
 type ClientOptions struct {
 	Endpoint string
 	Timeout  time.Duration
+
+	// PingInterval is how often websocket connections are pinged. A connection that receives
+	// nothing, not even a pong, for twice as long is closed.
+	PingInterval time.Duration
 }
 
-const defaultTimeout = 30 * time.Second
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultPingInterval = 30 * time.Second
+)
 
 func NewClient(options ClientOptions) (*Client, error) {
 	timeout := options.Timeout
@@ -36,16 +52,65 @@ func NewClient(options ClientOptions) (*Client, error) {
 		timeout = defaultTimeout
 	}
 
+	pingInterval := options.PingInterval
+	if pingInterval == 0 {
+		pingInterval = defaultPingInterval
+	}
+
 	if options.Endpoint == "" {
 		return nil, MissingFieldError{"Endpoint"}
 	}
 
 	return &Client{
-		httpClient: &http.Client{Timeout: timeout},
-		endpoint:   options.Endpoint,
+		httpClient:   &http.Client{Timeout: timeout},
+		endpoint:     options.Endpoint,
+		timeout:      timeout,
+		pingInterval: pingInterval,
 	}, nil
 }
 
+// IsWebsocket reports whether the client talks to its endpoint over a websocket.
+func (client *Client) IsWebsocket() bool {
+	return strings.HasPrefix(client.endpoint, "ws://") || strings.HasPrefix(client.endpoint, "wss://")
+}
+
+// wsConn returns the client's websocket connection, dialing it on first use or
+// after the previous connection was closed.
+func (client *Client) wsConn() (*wsConn, error) {
+	if !client.IsWebsocket() {
+		return nil, ErrSubscriptionsNotSupported
+	}
+
+	client.wsMu.Lock()
+	defer client.wsMu.Unlock()
+
+	if client.ws != nil && !client.ws.isClosed() {
+		return client.ws, nil
+	}
+
+	conn, err := dialWS(client.endpoint, client.timeout, client.pingInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	client.ws = conn
+
+	return conn, nil
+}
+
+// Close closes the websocket connection, if any, ending all its subscriptions.
+func (client *Client) Close() error {
+	client.wsMu.Lock()
+	defer client.wsMu.Unlock()
+
+	if client.ws != nil {
+		client.ws.close(ErrConnectionClosed)
+		client.ws = nil
+	}
+
+	return nil
+}
+
 type MissingFieldError struct {
 	name string
 }
@@ -82,6 +147,10 @@ type Response[T any] struct {
 }
 
 func Do[T any](client *Client, method string, params []any) (*Response[T], error) {
+	if client.IsWebsocket() {
+		return doWS[T](client, method, params)
+	}
+
 	id := client.generateID()
 
 	req := Request{
@@ -124,3 +193,29 @@ func Do[T any](client *Client, method string, params []any) (*Response[T], error
 
 	return &respBody, nil
 }
+
+// doWS performs a call over the client's websocket connection.
+func doWS[T any](client *Client, method string, params []any) (*Response[T], error) {
+	conn, err := client.wsConn()
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := conn.call([]Request{{Method: method, Params: params}}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := responses[0]
+	if raw.Error.Code != 0 {
+		return nil, raw.Error
+	}
+
+	respBody := Response[T]{JSONRPC: raw.JSONRPC, ID: raw.ID}
+
+	if err := json.Unmarshal(raw.Result, &respBody.Result); err != nil {
+		return nil, fmt.Errorf("could not decode result: %w", err)
+	}
+
+	return &respBody, nil
+}
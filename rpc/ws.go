@@ -0,0 +1,493 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscribeMethod    = "eth_subscribe"
+	unsubscribeMethod  = "eth_unsubscribe"
+	subscriptionMethod = "eth_subscription"
+
+	// subscriptionBufSize is how many notifications are buffered per subscription
+	// before new ones are dropped, see Subscription.Dropped.
+	subscriptionBufSize = 64
+)
+
+var (
+	ErrSubscriptionsNotSupported = errors.New("subscriptions require a websocket (ws:// or wss://) endpoint")
+	ErrConnectionClosed          = errors.New("websocket connection closed")
+)
+
+type pendingCall struct {
+	ch chan Response[json.RawMessage]
+
+	// subscription is registered by the read loop as soon as an eth_subscribe call
+	// succeeds, so no notification sent right after the response is lost.
+	subscription chan json.RawMessage
+}
+
+// wsConn multiplexes JSON-RPC calls and subscription notifications over a single websocket.
+type wsConn struct {
+	conn         *websocket.Conn
+	timeout      time.Duration
+	pingInterval time.Duration
+	writeMu      sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]*pendingCall
+	subs    map[string]chan json.RawMessage
+	dropped map[string]uint64
+	closed  chan struct{}
+	err     error
+}
+
+func dialWS(endpoint string, timeout, pingInterval time.Duration) (*wsConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial failed: %w", err)
+	}
+
+	resp.Body.Close()
+
+	wsc := &wsConn{
+		conn:         conn,
+		timeout:      timeout,
+		pingInterval: pingInterval,
+		pending:      make(map[int]*pendingCall),
+		subs:         make(map[string]chan json.RawMessage),
+		dropped:      make(map[string]uint64),
+		closed:       make(chan struct{}),
+	}
+
+	if err := wsc.extendReadDeadline(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not set read deadline: %w", err)
+	}
+
+	conn.SetPongHandler(func(string) error { return wsc.extendReadDeadline() })
+
+	go wsc.readLoop()
+	go wsc.pingLoop()
+
+	return wsc, nil
+}
+
+func (wsc *wsConn) isClosed() bool {
+	select {
+	case <-wsc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// close tears down the connection, failing pending calls and ending all subscriptions.
+func (wsc *wsConn) close(reason error) {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	if wsc.isClosed() {
+		return
+	}
+
+	wsc.err = reason
+	close(wsc.closed)
+	wsc.conn.Close()
+
+	for id, call := range wsc.pending {
+		close(call.ch)
+		delete(wsc.pending, id)
+	}
+
+	for id, ch := range wsc.subs {
+		close(ch)
+		delete(wsc.subs, id)
+	}
+}
+
+// wsMessage holds the fields of both responses and subscription notifications.
+type wsMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  JSONRPCError    `json:"error"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// extendReadDeadline gives the node two ping intervals to send a message or a pong, after which
+// reading fails and the connection is closed.
+func (wsc *wsConn) extendReadDeadline() error {
+	return wsc.conn.SetReadDeadline(time.Now().Add(2 * wsc.pingInterval))
+}
+
+// pingLoop pings the node every pingInterval until the connection is closed, so that a
+// connection the node silently dropped is detected by the read deadline.
+func (wsc *wsConn) pingLoop() {
+	ticker := time.NewTicker(wsc.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wsc.closed:
+			return
+
+		case <-ticker.C:
+			if err := wsc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsc.timeout)); err != nil {
+				wsc.close(fmt.Errorf("%w: ping failed: %w", ErrConnectionClosed, err))
+				return
+			}
+		}
+	}
+}
+
+func (wsc *wsConn) readLoop() {
+	for {
+		_, data, err := wsc.conn.ReadMessage()
+		if err != nil {
+			wsc.close(fmt.Errorf("%w: %w", ErrConnectionClosed, err))
+			return
+		}
+
+		if err := wsc.extendReadDeadline(); err != nil {
+			wsc.close(fmt.Errorf("%w: could not set read deadline: %w", ErrConnectionClosed, err))
+			return
+		}
+
+		var messages []wsMessage
+
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(trimmed, &messages)
+		} else {
+			messages = make([]wsMessage, 1)
+			err = json.Unmarshal(trimmed, &messages[0])
+		}
+
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range messages {
+			wsc.route(msg)
+		}
+	}
+}
+
+func (wsc *wsConn) route(msg wsMessage) {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	if msg.Method == subscriptionMethod {
+		ch, ok := wsc.subs[msg.Params.Subscription]
+		if !ok {
+			return
+		}
+
+		// never block the read loop on a slow consumer.
+		select {
+		case ch <- msg.Params.Result:
+		default:
+			wsc.dropped[msg.Params.Subscription]++
+		}
+
+		return
+	}
+
+	call, ok := wsc.pending[msg.ID]
+	if !ok {
+		return
+	}
+
+	delete(wsc.pending, msg.ID)
+
+	if call.subscription != nil && msg.Error.Code == 0 {
+		var subID string
+		if err := json.Unmarshal(msg.Result, &subID); err == nil {
+			wsc.subs[subID] = call.subscription
+		}
+	}
+
+	call.ch <- Response[json.RawMessage]{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  msg.Result,
+		Error:   msg.Error,
+	}
+}
+
+// call sends the requests, as a batch if asBatch is set, and waits for all their responses.
+// IDs are assigned by call and responses are returned in the same order as the requests.
+func (wsc *wsConn) call(requests []Request, asBatch bool) ([]Response[json.RawMessage], error) {
+	calls := make([]*pendingCall, len(requests))
+	batch := make([]Request, len(requests))
+
+	wsc.mu.Lock()
+
+	if wsc.isClosed() {
+		err := wsc.err
+		wsc.mu.Unlock()
+
+		return nil, err
+	}
+
+	for i, req := range requests {
+		wsc.nextID++
+
+		req.JSONRPC = "2.0"
+		req.ID = wsc.nextID
+		batch[i] = req
+
+		calls[i] = &pendingCall{ch: make(chan Response[json.RawMessage], 1)}
+		if req.Method == subscribeMethod {
+			calls[i].subscription = make(chan json.RawMessage, subscriptionBufSize)
+		}
+
+		wsc.pending[req.ID] = calls[i]
+	}
+
+	wsc.mu.Unlock()
+
+	var payload any = batch
+	if !asBatch && len(batch) == 1 {
+		payload = batch[0]
+	}
+
+	if err := wsc.write(payload); err != nil {
+		wsc.forget(batch)
+		return nil, err
+	}
+
+	timer := time.NewTimer(wsc.timeout)
+	defer timer.Stop()
+
+	responses := make([]Response[json.RawMessage], len(calls))
+
+	for i, call := range calls {
+		select {
+		case resp, ok := <-call.ch:
+			if !ok {
+				return nil, wsc.closeErr()
+			}
+
+			responses[i] = resp
+
+		case <-timer.C:
+			wsc.forget(batch)
+			return nil, fmt.Errorf("timed out waiting for response to %s", batch[i].Method)
+		}
+	}
+
+	return responses, nil
+}
+
+func (wsc *wsConn) write(payload any) error {
+	wsc.writeMu.Lock()
+	defer wsc.writeMu.Unlock()
+
+	if err := wsc.conn.SetWriteDeadline(time.Now().Add(wsc.timeout)); err != nil {
+		return fmt.Errorf("could not set write deadline: %w", err)
+	}
+
+	if err := wsc.conn.WriteJSON(payload); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	return nil
+}
+
+func (wsc *wsConn) forget(requests []Request) {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	for _, req := range requests {
+		delete(wsc.pending, req.ID)
+	}
+}
+
+func (wsc *wsConn) closeErr() error {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	return wsc.err
+}
+
+// subscription returns the notification channel registered for a subscription ID.
+func (wsc *wsConn) subscription(id string) (chan json.RawMessage, bool) {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	ch, ok := wsc.subs[id]
+
+	return ch, ok
+}
+
+// removeSubscription stops routing notifications for id and closes its channel, forgetting
+// how many were dropped.
+func (wsc *wsConn) removeSubscription(id string) {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	if ch, ok := wsc.subs[id]; ok {
+		close(ch)
+		delete(wsc.subs, id)
+	}
+
+	delete(wsc.dropped, id)
+}
+
+// droppedCount returns how many notifications of a subscription were dropped.
+func (wsc *wsConn) droppedCount(id string) uint64 {
+	wsc.mu.Lock()
+	defer wsc.mu.Unlock()
+
+	return wsc.dropped[id]
+}
+
+// Subscription delivers the notifications of an eth_subscribe subscription, decoded as T.
+// The channel returned by C is closed once the subscription ends, after which Err reports why.
+type Subscription[T any] struct {
+	ID string
+
+	conn     *wsConn
+	ch       chan T
+	done     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// C returns the channel notifications are delivered on.
+func (sub *Subscription[T]) C() <-chan T {
+	return sub.ch
+}
+
+// Err returns the reason the subscription ended, or nil while it is active
+// or if it ended through Unsubscribe.
+func (sub *Subscription[T]) Err() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return sub.err
+}
+
+// Dropped returns how many notifications were dropped because the consumer fell more than
+// the buffer behind.
+func (sub *Subscription[T]) Dropped() uint64 {
+	return sub.conn.droppedCount(sub.ID)
+}
+
+// Unsubscribe cancels the subscription on the server and closes the channel.
+func (sub *Subscription[T]) Unsubscribe() error {
+	sub.stopOnce.Do(func() { close(sub.stop) })
+	sub.conn.removeSubscription(sub.ID)
+	<-sub.done
+
+	responses, err := sub.conn.call([]Request{{Method: unsubscribeMethod, Params: []any{sub.ID}}}, false)
+	if err != nil {
+		return fmt.Errorf("could not unsubscribe: %w", err)
+	}
+
+	if responses[0].Error.Code != 0 {
+		return responses[0].Error
+	}
+
+	return nil
+}
+
+func (sub *Subscription[T]) forward(raw <-chan json.RawMessage) {
+	defer close(sub.done)
+	defer close(sub.ch)
+
+	for {
+		select {
+		case <-sub.stop:
+			return
+
+		case data, ok := <-raw:
+			if !ok {
+				if sub.conn.isClosed() {
+					sub.setErr(sub.conn.closeErr())
+				}
+
+				return
+			}
+
+			var value T
+			if err := json.Unmarshal(data, &value); err != nil {
+				sub.setErr(fmt.Errorf("could not decode notification: %w", err))
+				continue
+			}
+
+			select {
+			case sub.ch <- value:
+			case <-sub.stop:
+				return
+			}
+		}
+	}
+}
+
+func (sub *Subscription[T]) setErr(err error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.err = err
+}
+
+// Subscribe calls eth_subscribe with the given params and decodes each notification as T.
+// It requires a websocket endpoint.
+func Subscribe[T any](client *Client, params ...any) (*Subscription[T], error) {
+	conn, err := client.wsConn()
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := conn.call([]Request{{Method: subscribeMethod, Params: params}}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := responses[0]
+	if resp.Error.Code != 0 {
+		return nil, resp.Error
+	}
+
+	var id string
+	if err := json.Unmarshal(resp.Result, &id); err != nil {
+		return nil, fmt.Errorf("could not decode subscription id: %w", err)
+	}
+
+	raw, ok := conn.subscription(id)
+	if !ok {
+		return nil, fmt.Errorf("subscription %s was not registered: %w", id, conn.closeErr())
+	}
+
+	sub := &Subscription[T]{
+		ID:   id,
+		conn: conn,
+		ch:   make(chan T),
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	go sub.forward(raw)
+
+	return sub, nil
+}
@@ -0,0 +1,298 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testSubscriptionID = "0xcd0c3e8af590364c09d0fa6a1210faf5"
+
+// fakeNode is a websocket JSON-RPC stand-in answering eth_blockNumber and
+// pushing a couple of heads to every newHeads subscription.
+type fakeNode struct {
+	upgrader websocket.Upgrader
+	heads    []map[string]any
+
+	mu           sync.Mutex
+	unsubscribed bool
+
+	// silent nodes never answer pings.
+	silent bool
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *Client) {
+	t.Helper()
+
+	node := &fakeNode{
+		heads: []map[string]any{
+			{"hash": "0xh11", "number": "0x11", "parentHash": "0xh10"},
+			{"hash": "0xh12", "number": "0x12", "parentHash": "0xh11"},
+		},
+	}
+
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	client := mustMakeClient(t, "ws"+strings.TrimPrefix(server.URL, "http"))
+	t.Cleanup(func() { client.Close() })
+
+	return node, client
+}
+
+func (node *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := node.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	node.mu.Lock()
+	if node.silent {
+		conn.SetPingHandler(func(string) error { return nil })
+	}
+	node.mu.Unlock()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if strings.HasPrefix(string(data), "[") {
+			var batch []Request
+			if err := json.Unmarshal(data, &batch); err != nil {
+				return
+			}
+
+			responses := make([]map[string]any, 0, len(batch))
+			for _, req := range batch {
+				responses = append(responses, node.handle(req))
+			}
+
+			if err := conn.WriteJSON(responses); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return
+		}
+
+		if err := conn.WriteJSON(node.handle(req)); err != nil {
+			return
+		}
+
+		if req.Method == subscribeMethod {
+			node.mu.Lock()
+			heads := node.heads
+			node.mu.Unlock()
+
+			for _, head := range heads {
+				notification := map[string]any{
+					"jsonrpc": "2.0",
+					"method":  subscriptionMethod,
+					"params":  map[string]any{"subscription": testSubscriptionID, "result": head},
+				}
+
+				if err := conn.WriteJSON(notification); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (node *fakeNode) handle(req Request) map[string]any {
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+
+	switch req.Method {
+	case getCurrentBlockMethod:
+		resp["result"] = "0x12"
+
+	case subscribeMethod:
+		resp["result"] = testSubscriptionID
+
+	case unsubscribeMethod:
+		node.mu.Lock()
+		node.unsubscribed = true
+		node.mu.Unlock()
+
+		resp["result"] = true
+
+	default:
+		resp["error"] = JSONRPCError{Code: -32601, Message: "method not found"}
+	}
+
+	return resp
+}
+
+func TestWebsocket(t *testing.T) {
+	t.Run("it performs calls over the websocket", func(tt *testing.T) {
+		_, client := newFakeNode(tt)
+
+		response, err := client.GetCurrentBlockNumber()
+		if err != nil {
+			tt.Fatalf("could not get block number: %v", err)
+		}
+
+		if response.Result != "0x12" {
+			tt.Fatalf("got %s, want 0x12", response.Result)
+		}
+
+		results, err := Batch[string](client, getCurrentBlockMethod, [][]any{{}, {}})
+		if err != nil {
+			tt.Fatalf("batch failed: %v", err)
+		}
+
+		for i, result := range results {
+			if result.Err != nil || result.Result != "0x12" {
+				tt.Fatalf("(results[%d]) got %s (err: %v), want 0x12", i, result.Result, result.Err)
+			}
+		}
+	})
+
+	t.Run("it delivers new heads", func(tt *testing.T) {
+		node, client := newFakeNode(tt)
+
+		sub, err := client.SubscribeNewHeads()
+		if err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		for _, want := range []string{"0x11", "0x12"} {
+			select {
+			case header := <-sub.C():
+				if header.Number != want {
+					tt.Fatalf("got %s, want %s", header.Number, want)
+				}
+
+			case <-time.After(time.Second):
+				tt.Fatalf("timed out waiting for head %s", want)
+			}
+		}
+
+		if err := sub.Unsubscribe(); err != nil {
+			tt.Fatalf("could not unsubscribe: %v", err)
+		}
+
+		if _, open := <-sub.C(); open {
+			tt.Fatalf("channel should be closed after unsubscribing")
+		}
+
+		node.mu.Lock()
+		defer node.mu.Unlock()
+
+		if !node.unsubscribed {
+			tt.Fatalf("eth_unsubscribe was not sent")
+		}
+	})
+
+	t.Run("it counts the notifications dropped by a slow consumer", func(tt *testing.T) {
+		node, client := newFakeNode(tt)
+
+		const total = 2 * subscriptionBufSize
+
+		node.mu.Lock()
+		node.heads = nil
+		for i := range total {
+			node.heads = append(node.heads, map[string]any{"number": fmt.Sprintf("0x%x", i)})
+		}
+		node.mu.Unlock()
+
+		sub, err := client.SubscribeNewHeads()
+		if err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for sub.Dropped() == 0 {
+			if time.Now().After(deadline) {
+				tt.Fatalf("timed out waiting for notifications to be dropped")
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		received := 0
+
+	drain:
+		for {
+			select {
+			case <-sub.C():
+				received++
+
+			case <-time.After(100 * time.Millisecond):
+				break drain
+			}
+		}
+
+		if got := received + int(sub.Dropped()); got != total {
+			tt.Fatalf("got %d received and dropped notifications, want %d", got, total)
+		}
+	})
+
+	t.Run("it keeps connections that answer pings", func(tt *testing.T) {
+		_, client := newFakeNode(tt)
+		client.pingInterval = 10 * time.Millisecond
+
+		conn, err := client.wsConn()
+		if err != nil {
+			tt.Fatalf("could not connect: %v", err)
+		}
+
+		time.Sleep(10 * client.pingInterval)
+
+		if conn.isClosed() {
+			tt.Fatalf("connection was closed: %v", conn.closeErr())
+		}
+	})
+
+	t.Run("it closes connections that stop answering pings", func(tt *testing.T) {
+		node, client := newFakeNode(tt)
+		client.pingInterval = 10 * time.Millisecond
+
+		node.mu.Lock()
+		node.silent = true
+		node.mu.Unlock()
+
+		sub, err := client.SubscribeNewHeads()
+		if err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		timeout := time.After(time.Second)
+
+		for open := true; open; {
+			select {
+			case _, open = <-sub.C():
+			case <-timeout:
+				tt.Fatalf("timed out waiting for the connection to be closed")
+			}
+		}
+
+		if err := sub.Err(); !errors.Is(err, ErrConnectionClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrConnectionClosed)
+		}
+	})
+
+	t.Run("it requires a websocket endpoint to subscribe", func(tt *testing.T) {
+		client := mustMakeClient(tt, testEndpoint)
+
+		_, err := client.SubscribeNewHeads()
+		if !errors.Is(err, ErrSubscriptionsNotSupported) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrSubscriptionsNotSupported)
+		}
+	})
+}
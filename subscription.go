@@ -0,0 +1,356 @@
+package txnotify
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/expr"
+)
+
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// Direction selects transactions by the subscribed address's side of them.
+type Direction string
+
+const (
+	// DirectionBoth matches transactions sent or received by the address. It is the default.
+	DirectionBoth Direction = "both"
+
+	// DirectionIncoming matches transactions received by the address.
+	DirectionIncoming Direction = "incoming"
+
+	// DirectionOutgoing matches transactions sent by the address.
+	DirectionOutgoing Direction = "outgoing"
+)
+
+// selectorLen is the length of a hex-encoded 4-byte method selector, including its 0x prefix.
+const selectorLen = 10
+
+// Subscription describes the transactions of an address to be notified. Empty filters match
+// everything, so a Subscription with only an Address matches every transaction it is part of.
+type Subscription struct {
+	Address string `json:"address"`
+
+	// Direction restricts the subscription to incoming or outgoing transactions.
+	Direction Direction `json:"direction,omitempty"`
+
+	// MinValue is the minimum value transferred, in wei, as a decimal or 0x-prefixed hex string.
+	// It describes transactions, so a subscription setting it matches no token or NFT transfers.
+	MinValue string `json:"minValue,omitempty"`
+
+	// Counterparties, if set, only matches transactions with one of these addresses on the other side.
+	Counterparties []string `json:"counterparties,omitempty"`
+
+	// ExcludedCounterparties never matches transactions with one of these addresses on the other side.
+	ExcludedCounterparties []string `json:"excludedCounterparties,omitempty"`
+
+	// Selectors, if set, only matches transactions calling one of these 4-byte methods (e.g. "0xa9059cbb").
+	// Like MinValue, it makes the subscription match no token or NFT transfers.
+	Selectors []string `json:"selectors,omitempty"`
+
+	// Expression, if set, only matches transactions satisfying it, in the language of the expr package,
+	// e.g. "value > 1e18 && input.selector == 0xa9059cbb". Like MinValue, it makes the subscription
+	// match no token or NFT transfers.
+	Expression string `json:"expression,omitempty"`
+
+	// Deployments sends an EventDeployed notification when the address deploys a contract,
+	// with the creation transaction's ContractAddress set. Only contract creation transactions
+	// sent by the address are seen, not contracts created internally by other contracts.
+	Deployments bool `json:"deployments,omitempty"`
+
+	// FollowDeployments subscribes to every contract the address deploys, with no filters.
+	FollowDeployments bool `json:"followDeployments,omitempty"`
+
+	// program is the compiled Expression, set by Compiled.
+	program *expr.Program
+}
+
+// subscriptionRef is a subscription held by the watcher, with the number of Subscribe calls for it.
+type subscriptionRef struct {
+	sub  Subscription
+	refs int
+}
+
+// normalize returns the subscription with its addresses, direction and selectors in canonical form.
+func (sub Subscription) normalize() Subscription {
+	normalizeAll := func(values []string, fn func(string) string) []string {
+		if len(values) == 0 {
+			return nil
+		}
+
+		normed := make([]string, len(values))
+		for i, v := range values {
+			normed[i] = fn(v)
+		}
+
+		slices.Sort(normed)
+
+		return slices.Compact(normed)
+	}
+
+	sub.Address = NormalizeAddress(sub.Address)
+	sub.Counterparties = normalizeAll(sub.Counterparties, NormalizeAddress)
+	sub.ExcludedCounterparties = normalizeAll(sub.ExcludedCounterparties, NormalizeAddress)
+	sub.Selectors = normalizeAll(sub.Selectors, strings.ToLower)
+
+	if sub.Direction == "" {
+		sub.Direction = DirectionBoth
+	}
+
+	if minValue, ok := parseQuantity(sub.MinValue); ok {
+		sub.MinValue = minValue.String()
+	}
+
+	sub.Expression = strings.TrimSpace(sub.Expression)
+
+	return sub
+}
+
+// Compiled validates the subscription and returns it in normalized form with its expression
+// compiled, so matching transactions does not parse it again.
+func (sub Subscription) Compiled() (Subscription, error) {
+	if err := sub.validateFilters(); err != nil {
+		return Subscription{}, err
+	}
+
+	sub = sub.normalize()
+
+	if sub.Expression != "" && sub.program == nil {
+		program, err := expr.Compile(sub.Expression)
+		if err != nil {
+			return Subscription{}, fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+		}
+
+		sub.program = program
+	}
+
+	return sub, nil
+}
+
+// Validate reports whether the subscription has an address and well-formed filters.
+func (sub Subscription) Validate() error {
+	_, err := sub.Compiled()
+
+	return err
+}
+
+func (sub Subscription) validateFilters() error {
+	if strings.TrimSpace(sub.Address) == "" {
+		return fmt.Errorf("%w: missing address", ErrInvalidSubscription)
+	}
+
+	switch sub.Direction {
+	case "", DirectionBoth, DirectionIncoming, DirectionOutgoing:
+	default:
+		return fmt.Errorf("%w: unknown direction '%s'", ErrInvalidSubscription, sub.Direction)
+	}
+
+	if sub.MinValue != "" {
+		if _, ok := parseQuantity(sub.MinValue); !ok {
+			return fmt.Errorf("%w: could not parse minimum value '%s'", ErrInvalidSubscription, sub.MinValue)
+		}
+	}
+
+	for _, selector := range sub.Selectors {
+		if !isSelector(selector) {
+			return fmt.Errorf("%w: selector '%s' is not a 0x-prefixed 4-byte hex string", ErrInvalidSubscription, selector)
+		}
+	}
+
+	return nil
+}
+
+// isSelector reports whether s is a hex-encoded 4-byte method selector, such as "0xa9059cbb".
+// It is matched against transaction inputs case-insensitively.
+func isSelector(s string) bool {
+	if len(s) != selectorLen || !strings.HasPrefix(strings.ToLower(s), "0x") {
+		return false
+	}
+
+	_, err := hex.DecodeString(s[2:])
+
+	return err == nil
+}
+
+// Key identifies the subscription: subscriptions with the same address and filters share a key.
+func (sub Subscription) Key() string {
+	sub = sub.normalize()
+
+	return strings.Join([]string{
+		sub.Address,
+		string(sub.Direction),
+		sub.MinValue,
+		strings.Join(sub.Counterparties, ","),
+		strings.Join(sub.ExcludedCounterparties, ","),
+		strings.Join(sub.Selectors, ","),
+		sub.Expression,
+		strconv.FormatBool(sub.Deployments),
+		strconv.FormatBool(sub.FollowDeployments),
+	}, "|")
+}
+
+// Matches reports whether the subscription's filters select the transaction. Subscriptions
+// with an invalid expression match nothing.
+func (sub Subscription) Matches(tx ethereum.Transaction) bool {
+	sub = sub.normalize()
+
+	to := ""
+	if tx.To != nil {
+		to = *tx.To
+	}
+
+	if !sub.MatchesParties(tx.From, to) {
+		return false
+	}
+
+	if sub.MinValue != "" {
+		minValue, _ := parseQuantity(sub.MinValue)
+
+		value, ok := parseQuantity(tx.Value)
+		if !ok || value.Cmp(minValue) < 0 {
+			return false
+		}
+	}
+
+	if len(sub.Selectors) > 0 {
+		if len(tx.Input) < selectorLen || !slices.Contains(sub.Selectors, strings.ToLower(tx.Input[:selectorLen])) {
+			return false
+		}
+	}
+
+	if sub.Expression == "" {
+		return true
+	}
+
+	program := sub.program
+	if program == nil {
+		var err error
+
+		if program, err = expr.Compile(sub.Expression); err != nil {
+			return false
+		}
+	}
+
+	return program.Match(tx)
+}
+
+// MatchesTransfer reports whether the subscription selects a token or NFT transfer between from
+// and to. Only the direction and counterparty filters apply to transfers: the others describe
+// transactions, so a subscription setting any of them matches no transfer.
+func (sub Subscription) MatchesTransfer(from, to string) bool {
+	if sub.MinValue != "" || len(sub.Selectors) > 0 || strings.TrimSpace(sub.Expression) != "" {
+		return false
+	}
+
+	return sub.MatchesParties(from, to)
+}
+
+// MatchesParties reports whether the subscription's direction and counterparty filters select
+// a transaction or transfer between from and to.
+func (sub Subscription) MatchesParties(from, to string) bool {
+	sub = sub.normalize()
+
+	from = NormalizeAddress(from)
+	if to != "" {
+		to = NormalizeAddress(to)
+	}
+
+	var counterparty string
+
+	switch {
+	case from == sub.Address && to == sub.Address:
+		counterparty = sub.Address
+	case from == sub.Address && sub.Direction != DirectionIncoming:
+		counterparty = to
+	case to == sub.Address && sub.Direction != DirectionOutgoing:
+		counterparty = from
+	default:
+		return false
+	}
+
+	if len(sub.Counterparties) > 0 && !slices.Contains(sub.Counterparties, counterparty) {
+		return false
+	}
+
+	return !slices.Contains(sub.ExcludedCounterparties, counterparty)
+}
+
+// parseQuantity parses a decimal or 0x-prefixed hex integer.
+func parseQuantity(s string) (*big.Int, bool) {
+	if strings.HasPrefix(s, "0x") {
+		return new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	}
+
+	return new(big.Int).SetString(s, 10)
+}
+
+// subscriptionsFor returns the subscriptions registered for an address.
+func (watcher *Watcher) subscriptionsFor(address string) []Subscription {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	var subs []Subscription
+
+	for _, ref := range watcher.subscriptions {
+		if ref.sub.Address == address {
+			subs = append(subs, ref.sub)
+		}
+	}
+
+	return subs
+}
+
+// filterTxs keeps the transactions matched by any subscription of address. Addresses without
+// subscriptions, such as those passed to Backfill, are not filtered.
+func (watcher *Watcher) filterTxs(address string, txList []ethereum.Transaction) []ethereum.Transaction {
+	subs := watcher.subscriptionsFor(address)
+	if len(subs) == 0 {
+		return txList
+	}
+
+	return matchingTxs(txList, subs)
+}
+
+// filterContents keeps the transactions and transfers of an event matched by any of subs, see
+// Subscription.Matches and Subscription.MatchesTransfer. Both the Watcher and FilterEvent use it.
+func filterContents(event Event, subs []Subscription) Event {
+	event.Transactions = matchingTxs(event.Transactions, subs)
+	event.TokenTransfers = matchingTransfers(event.TokenTransfers, subs, tokenTransferParties)
+	event.NFTTransfers = matchingTransfers(event.NFTTransfers, subs, nftTransferParties)
+
+	return event
+}
+
+// matchingTxs returns the transactions matched by any of subs.
+func matchingTxs(txList []ethereum.Transaction, subs []Subscription) []ethereum.Transaction {
+	var matched []ethereum.Transaction
+
+	for _, tx := range txList {
+		if slices.ContainsFunc(subs, func(sub Subscription) bool { return sub.Matches(tx) }) {
+			matched = append(matched, tx)
+		}
+	}
+
+	return matched
+}
+
+// matchingTransfers returns the transfers matched by any of subs.
+func matchingTransfers[T any](transfers []T, subs []Subscription, parties func(T) (string, string)) []T {
+	var matched []T
+
+	for _, transfer := range transfers {
+		from, to := parties(transfer)
+
+		if slices.ContainsFunc(subs, func(sub Subscription) bool { return sub.MatchesTransfer(from, to) }) {
+			matched = append(matched, transfer)
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,141 @@
+package txnotify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+func TestSubscription(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+		carol = "0xca201"
+
+		transferSelector = "0xa9059cbb"
+		oneEther         = "0xde0b6b3a7640000"
+	)
+
+	outgoing := ethereum.Transaction{From: alice, To: ptr(bob), Value: oneEther, Input: "0x"}
+	incoming := ethereum.Transaction{From: carol, To: ptr(alice), Value: "0x1", Input: transferSelector + "00"}
+
+	testCases := []struct {
+		name string
+		sub  Subscription
+		tx   ethereum.Transaction
+		want bool
+	}{
+		{"it matches any transaction without filters", Subscription{Address: alice}, incoming, true},
+		{"it ignores transactions of other addresses", Subscription{Address: bob}, incoming, false},
+		{"it matches outgoing transactions", Subscription{Address: alice, Direction: DirectionOutgoing}, outgoing, true},
+		{"it skips incoming transactions", Subscription{Address: alice, Direction: DirectionOutgoing}, incoming, false},
+		{"it matches incoming transactions", Subscription{Address: "0xA11CE", Direction: DirectionIncoming}, incoming, true},
+		{"it matches values at the threshold", Subscription{Address: alice, MinValue: "1000000000000000000"}, outgoing, true},
+		{"it skips values below the threshold", Subscription{Address: alice, MinValue: "0x2"}, incoming, false},
+		{"it matches allowed counterparties", Subscription{Address: alice, Counterparties: []string{carol}}, incoming, true},
+		{"it skips other counterparties", Subscription{Address: alice, Counterparties: []string{carol}}, outgoing, false},
+		{"it skips excluded counterparties", Subscription{Address: alice, ExcludedCounterparties: []string{bob}}, outgoing, false},
+		{"it matches selectors", Subscription{Address: alice, Selectors: []string{"0xA9059CBB"}}, incoming, true},
+		{"it skips other selectors", Subscription{Address: alice, Selectors: []string{transferSelector}}, outgoing, false},
+		{"it matches expressions", Subscription{Address: alice, Expression: "to == 0xb0b && value >= 1e18"}, outgoing, true},
+		{"it skips transactions failing the expression", Subscription{Address: alice, Expression: "value > 1e18"}, outgoing, false},
+		{"it combines expressions with filters", Subscription{Address: alice, Direction: DirectionIncoming, Expression: "value > 0"}, outgoing, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(tt *testing.T) {
+			if got := tc.sub.Matches(tc.tx); got != tc.want {
+				tt.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("it only matches transfers without transaction filters", func(tt *testing.T) {
+		transferCases := []struct {
+			sub  Subscription
+			want bool
+		}{
+			{Subscription{Address: alice, Direction: DirectionOutgoing}, true},
+			{Subscription{Address: alice, Direction: DirectionIncoming}, false},
+			{Subscription{Address: alice, ExcludedCounterparties: []string{bob}}, false},
+			{Subscription{Address: alice, MinValue: "0x1"}, false},
+			{Subscription{Address: alice, Selectors: []string{transferSelector}}, false},
+			{Subscription{Address: alice, Expression: "to == 0xb0b && value > 1e18"}, false},
+		}
+
+		for _, tc := range transferCases {
+			if got := tc.sub.MatchesTransfer(alice, bob); got != tc.want {
+				tt.Fatalf("(%+v) got %v, want %v", tc.sub, got, tc.want)
+			}
+		}
+	})
+
+	t.Run("it rejects malformed filters", func(tt *testing.T) {
+		invalid := []Subscription{
+			{},
+			{Address: alice, Direction: "sideways"},
+			{Address: alice, MinValue: "1e18"},
+			{Address: alice, Selectors: []string{"transfer"}},
+			{Address: alice, Selectors: []string{"0xtransfer"}},
+			{Address: alice, Selectors: []string{"0xa9059cb"}},
+			{Address: alice, Selectors: []string{"a9059cbb00"}},
+			{Address: alice, Expression: "value >"},
+		}
+
+		for _, sub := range invalid {
+			if err := sub.Validate(); !errors.Is(err, ErrInvalidSubscription) {
+				tt.Fatalf("(%+v) got: '%v', want: '%v'", sub, err, ErrInvalidSubscription)
+			}
+		}
+	})
+
+	t.Run("it keys equivalent subscriptions the same", func(tt *testing.T) {
+		a := Subscription{Address: "0x0A11CE", MinValue: "0x10", Counterparties: []string{bob, carol}}
+		b := Subscription{Address: alice, Direction: DirectionBoth, MinValue: "16", Counterparties: []string{carol, bob}}
+
+		if a.Key() != b.Key() {
+			tt.Fatalf("got different keys '%s' and '%s'", a.Key(), b.Key())
+		}
+	})
+}
+
+func TestWatcherFilters(t *testing.T) {
+	const (
+		treasury = "0x7ea5"
+		alice    = "0xa11ce"
+		tenEther = "0x8ac7230489e80000"
+	)
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.setHead("0x10")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+
+	sub := Subscription{Address: treasury, Direction: DirectionOutgoing, MinValue: tenEther}
+	if err := watcher.Subscribe(sub); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	chain.addBlock("0x11", "0xh11", "0xh10",
+		ethereum.Transaction{Hash: "0xsmall", From: treasury, To: ptr(alice), Value: "0x1"},
+		ethereum.Transaction{Hash: "0xincoming", From: alice, To: ptr(treasury), Value: tenEther},
+		ethereum.Transaction{Hash: "0xlarge", From: treasury, To: ptr(alice), Value: tenEther},
+	)
+	chain.setHead("0x11")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventMined, treasury, "0xlarge")
+
+	for _, hash := range []string{"0xsmall", "0xincoming"} {
+		if notifier.has(recordedEvent{kind: EventMined, address: treasury, hash: hash}) {
+			t.Fatalf("%s should have been filtered out", hash)
+		}
+	}
+}
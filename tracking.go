@@ -0,0 +1,404 @@
+package txnotify
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+var ErrInvalidHash = errors.New("invalid transaction hash")
+
+// txHashLen is the length of a hex-encoded 32-byte transaction hash, including its 0x prefix.
+const txHashLen = 66
+
+type trackState int
+
+const (
+	// trackUnseen transactions have not been found yet.
+	trackUnseen trackState = iota
+
+	// trackPending transactions are in the mempool.
+	trackPending
+
+	// trackIncluded transactions are in a block, waiting for confirmations.
+	trackIncluded
+)
+
+// trackedTx is a transaction followed by TrackTx. lastSeen is the last time it was found.
+type trackedTx struct {
+	state     trackState
+	tx        ethereum.Transaction
+	blockNum  string
+	blockHash string
+	lastSeen  time.Time
+}
+
+// TrackTx follows a single transaction through its lifecycle, sending each change to the notifier
+// with the transaction's sender as the address:
+//   - EventPending once it is seen in the mempool.
+//   - EventUnconfirmed once it is included in a block, if Confirmations is set.
+//   - EventMined once its block has Confirmations confirmations, with its receipt attached
+//     (so failed executions can be told apart). Tracking ends here.
+//   - EventReverted if its block is orphaned by a reorganization, after which it is followed again.
+//   - EventReplaced if a different transaction with the same sender and nonce is mined, or
+//     EventDropped if the node does not know it for DropAfter. Tracking ends in both cases.
+//
+// The transaction is looked up right away, and again on every new block. A transaction that is
+// never found has no known sender, so its EventDropped has an empty Address: notifiers routing
+// events by address only deliver it to their AnyAddress destinations, and a MultiNotifier only
+// to its routes without Subscriptions.
+func (watcher *Watcher) TrackTx(hash string) error {
+	if !isTxHash(hash) {
+		return fmt.Errorf("%w: '%s' is not a 0x-prefixed 32-byte hex string", ErrInvalidHash, hash)
+	}
+
+	hash = strings.ToLower(hash)
+
+	watcher.trackMu.Lock()
+
+	if watcher.tracked == nil {
+		watcher.tracked = make(map[string]*trackedTx)
+	}
+
+	if _, ok := watcher.tracked[hash]; !ok {
+		watcher.tracked[hash] = &trackedTx{tx: ethereum.Transaction{Hash: hash}, lastSeen: time.Now()}
+	}
+
+	watcher.trackMu.Unlock()
+
+	watcher.lookupTracked([]string{hash}, time.Now())
+
+	return nil
+}
+
+// isTxHash reports whether s is a hex-encoded 32-byte transaction hash.
+func isTxHash(s string) bool {
+	if len(s) != txHashLen || !strings.HasPrefix(strings.ToLower(s), "0x") {
+		return false
+	}
+
+	_, err := hex.DecodeString(s[2:])
+
+	return err == nil
+}
+
+// trackUpdate is a lifecycle notification of a tracked transaction, collected under trackMu and
+// sent once it is released.
+type trackUpdate struct {
+	kind EventKind
+	tx   ethereum.Transaction
+}
+
+// trackLookup is what the cache or the node know about a tracked transaction.
+type trackLookup struct {
+	hash  string
+	tx    ethereum.Transaction
+	found bool
+}
+
+// checkTracked looks up every tracked transaction that is not included in a block yet, and
+// checks the confirmations of those that are.
+func (watcher *Watcher) checkTracked(now time.Time) {
+	watcher.trackMu.Lock()
+
+	if len(watcher.tracked) == 0 {
+		watcher.trackMu.Unlock()
+		return
+	}
+
+	var hashes []string
+
+	for hash, entry := range watcher.tracked {
+		if entry.state != trackIncluded {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	watcher.trackMu.Unlock()
+
+	watcher.lookupTracked(hashes, now)
+}
+
+// lookupTracked looks up the given tracked transactions, and checks the confirmations of those
+// included in a block. The node is asked without holding trackMu, so TrackTx and block
+// processing are not held up by its latency.
+func (watcher *Watcher) lookupTracked(hashes []string, now time.Time) {
+	lookups := make([]trackLookup, 0, len(hashes))
+
+	for _, hash := range hashes {
+		tx, found := watcher.findTx(hash)
+		if found && tx.BlockNumber != nil {
+			tx = watcher.withReceipt(tx)
+		}
+
+		lookups = append(lookups, trackLookup{hash: hash, tx: tx, found: found})
+	}
+
+	latestBlock := watcher.copyState().latestBlock
+
+	var updates []trackUpdate
+
+	watcher.trackMu.Lock()
+
+	for _, lookup := range lookups {
+		entry, ok := watcher.tracked[lookup.hash]
+
+		// settled by a block in the meantime.
+		if !ok || entry.state == trackIncluded {
+			continue
+		}
+
+		updates = watcher.updateTracked(lookup, entry, now, updates)
+	}
+
+	for hash, entry := range watcher.tracked {
+		if entry.state == trackIncluded {
+			updates = watcher.checkConfirmations(hash, entry, latestBlock, updates)
+		}
+	}
+
+	watcher.trackMu.Unlock()
+
+	watcher.sendTracked(updates)
+}
+
+// updateTracked applies the lookup of a tracked transaction, appending the notifications it
+// leads to to updates. Callers must hold trackMu.
+func (watcher *Watcher) updateTracked(
+	lookup trackLookup,
+	entry *trackedTx,
+	now time.Time,
+	updates []trackUpdate,
+) []trackUpdate {
+	if !lookup.found {
+		if now.Sub(entry.lastSeen) < watcher.dropAfter {
+			return updates
+		}
+
+		watcher.logger.Info("tracked transaction dropped", "hash", lookup.hash, "lastSeen", entry.lastSeen)
+		delete(watcher.tracked, lookup.hash)
+
+		return append(updates, trackUpdate{kind: EventDropped, tx: entry.tx})
+	}
+
+	entry.lastSeen = now
+
+	if lookup.tx.BlockNumber == nil {
+		entry.tx = lookup.tx
+
+		if entry.state == trackUnseen {
+			entry.state = trackPending
+			updates = append(updates, trackUpdate{kind: EventPending, tx: lookup.tx})
+		}
+
+		return updates
+	}
+
+	return watcher.includeTracked(entry, lookup.tx, updates)
+}
+
+// findTx returns a transaction from the cache if its cached block still contains it, or from the node.
+func (watcher *Watcher) findTx(hash string) (ethereum.Transaction, bool) {
+	if tx, err := watcher.cache.GetTx(hash); err == nil && tx.BlockNumber != nil {
+		block, err := watcher.cache.GetBlock(*tx.BlockNumber)
+		if err == nil && slices.ContainsFunc(block.Transactions, func(t ethereum.Transaction) bool { return t.Hash == hash }) {
+			tx.BlockHash = &block.Hash
+			return tx, true
+		}
+	}
+
+	resp, err := watcher.rpcClient.GetTransactionByHash(hash)
+	if err != nil {
+		watcher.logger.Debug("could not get tracked transaction", "hash", hash, "error", err)
+		return ethereum.Transaction{}, false
+	}
+
+	return resp.Result, resp.Result.Hash != ""
+}
+
+// withReceipt attaches its receipt to a mined transaction, unless it has one or it cannot be fetched.
+func (watcher *Watcher) withReceipt(tx ethereum.Transaction) ethereum.Transaction {
+	if tx.Receipt != nil {
+		return tx
+	}
+
+	receipt, err := watcher.fetchReceipt(tx.Hash)
+	if err != nil {
+		watcher.logger.Debug("could not get tracked transaction receipt", "hash", tx.Hash, "error", err)
+		return tx
+	}
+
+	tx.Receipt = &receipt
+
+	return tx
+}
+
+// includeTracked records the block a tracked transaction was included in, appending the
+// notifications it leads to to updates. Callers must hold trackMu.
+func (watcher *Watcher) includeTracked(
+	entry *trackedTx,
+	tx ethereum.Transaction,
+	updates []trackUpdate,
+) []trackUpdate {
+	entry.state = trackIncluded
+	entry.tx = tx
+	entry.blockNum = *tx.BlockNumber
+	entry.blockHash = ""
+
+	if tx.BlockHash != nil {
+		entry.blockHash = *tx.BlockHash
+	}
+
+	watcher.logger.Info("tracked transaction included", "hash", tx.Hash, "blockNum", entry.blockNum)
+
+	if watcher.confirmations > 0 {
+		updates = append(updates, trackUpdate{kind: EventUnconfirmed, tx: tx})
+	}
+
+	return updates
+}
+
+// checkConfirmations stops tracking an included transaction once it has enough confirmations,
+// appending EventMined to updates, or appends EventReverted if the cached block at its height no
+// longer is its block. Callers must hold trackMu.
+func (watcher *Watcher) checkConfirmations(
+	hash string,
+	entry *trackedTx,
+	latestBlock string,
+	updates []trackUpdate,
+) []trackUpdate {
+	if block, err := watcher.cache.GetBlock(entry.blockNum); err == nil && entry.blockHash != "" && block.Hash != entry.blockHash {
+		watcher.logger.Warn("tracked transaction reverted", "hash", hash, "blockNum", entry.blockNum)
+
+		entry.state = trackPending
+		entry.blockNum = ""
+		entry.blockHash = ""
+
+		return append(updates, trackUpdate{kind: EventReverted, tx: entry.tx})
+	}
+
+	latest, err := strToHex(latestBlock)
+	if err != nil {
+		return updates
+	}
+
+	num, err := strToHex(entry.blockNum)
+	if err != nil || latest-num < watcher.confirmations {
+		return updates
+	}
+
+	watcher.logger.Info("tracked transaction confirmed", "hash", hash, "confirmations", latest-num)
+	delete(watcher.tracked, hash)
+
+	return append(updates, trackUpdate{kind: EventMined, tx: entry.tx})
+}
+
+// settleTracked updates the tracked transactions that are not included yet with a processed block:
+// those in it are included, and those whose sender and nonce it used are replaced. The receipts
+// of the included ones are fetched without holding trackMu.
+func (watcher *Watcher) settleTracked(blockNum string, block ethereum.Block) {
+	watcher.trackMu.Lock()
+
+	if len(watcher.tracked) == 0 {
+		watcher.trackMu.Unlock()
+		return
+	}
+
+	var (
+		included []ethereum.Transaction
+		updates  []trackUpdate
+	)
+
+	for _, tx := range block.Transactions {
+		for hash, entry := range watcher.tracked {
+			if entry.state == trackIncluded {
+				continue
+			}
+
+			if tx.Hash == hash {
+				if tx.BlockNumber == nil {
+					tx.BlockNumber = &blockNum
+				}
+
+				if tx.BlockHash == nil {
+					tx.BlockHash = &block.Hash
+				}
+
+				included = append(included, tx)
+
+				continue
+			}
+
+			if entry.state == trackPending && senderNonce(entry.tx) == senderNonce(tx) {
+				watcher.logger.Info("tracked transaction replaced", "hash", hash, "replacedBy", tx.Hash)
+				delete(watcher.tracked, hash)
+
+				entry.tx.ReplacedBy = &tx.Hash
+				updates = append(updates, trackUpdate{kind: EventReplaced, tx: entry.tx})
+			}
+		}
+	}
+
+	watcher.trackMu.Unlock()
+
+	for i, tx := range included {
+		included[i] = watcher.withReceipt(tx)
+	}
+
+	latestBlock := watcher.copyState().latestBlock
+
+	watcher.trackMu.Lock()
+
+	for _, tx := range included {
+		entry, ok := watcher.tracked[tx.Hash]
+
+		// already settled by a lookup in the meantime.
+		if !ok || entry.state == trackIncluded {
+			continue
+		}
+
+		updates = watcher.includeTracked(entry, tx, updates)
+		updates = watcher.checkConfirmations(tx.Hash, entry, latestBlock, updates)
+	}
+
+	watcher.trackMu.Unlock()
+
+	watcher.sendTracked(updates)
+}
+
+// sendTracked sends the notifications collected under trackMu, in order.
+func (watcher *Watcher) sendTracked(updates []trackUpdate) {
+	for _, update := range updates {
+		watcher.notifyTracked(update.kind, update.tx)
+	}
+}
+
+// notifyTracked sends a lifecycle notification for a tracked transaction to its sender, with the
+// block it is included in, if any. Transactions that were never found have no sender, and are
+// notified with an empty address, see TrackTx.
+func (watcher *Watcher) notifyTracked(kind EventKind, tx ethereum.Transaction) {
+	address := ""
+	if tx.From != "" {
+		address = NormalizeAddress(tx.From)
+	}
+
+	var block ethereum.Block
+
+	if kind != EventReverted && tx.BlockNumber != nil && tx.BlockHash != nil {
+		block = ethereum.Block{Number: *tx.BlockNumber, Hash: *tx.BlockHash}
+
+		if cached, err := watcher.cache.GetBlock(block.Number); err == nil && cached.Hash == block.Hash {
+			block.Timestamp = cached.Timestamp
+		}
+	}
+
+	event := watcher.blockEvent(kind, address, block)
+	event.Transactions = []ethereum.Transaction{tx}
+
+	watcher.notify(event)
+}
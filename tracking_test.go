@@ -0,0 +1,272 @@
+package txnotify
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+func TestWatcherTrackTx(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+	)
+
+	setup := func(tt *testing.T) (*mockChain, *recordingNotifier, *Watcher) {
+		tt.Helper()
+
+		chain := newMockChain()
+		chain.addBlock("0x10", "0xh10", "0xh9")
+		chain.setHead("0x10")
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.confirmations = 2
+		watcher.dropAfter = time.Minute
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		return chain, notifier, watcher
+	}
+
+	advance := func(chain *mockChain, watcher *Watcher, num, hash, parentHash string, txList ...ethereum.Transaction) {
+		chain.addBlock(num, hash, parentHash, txList...)
+		chain.setHead(num)
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+	}
+
+	t.Run("it rejects malformed hashes", func(tt *testing.T) {
+		_, _, watcher := setup(tt)
+
+		for _, hash := range []string{"", "0x", "abc", "0xa", txHash("a")[:65], txHash("a") + "0", "0x" + strings.Repeat("g", 64)} {
+			if err := watcher.TrackTx(hash); !errors.Is(err, ErrInvalidHash) {
+				tt.Fatalf("(%s) got: '%v', want: '%v'", hash, err, ErrInvalidHash)
+			}
+		}
+	})
+
+	t.Run("it follows a transaction from pending to confirmed", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		tx := ethereum.Transaction{Hash: txHash("a"), From: alice, To: ptr(bob), Nonce: "0x1"}
+		chain.addPending(tx)
+
+		if err := watcher.TrackTx("0x" + strings.ToUpper(tx.Hash[2:])); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		notifier.waitFor(tt, EventPending, alice, tx.Hash)
+
+		advance(chain, watcher, "0x11", "0xh11", "0xh10", tx)
+		notifier.waitFor(tt, EventUnconfirmed, alice, tx.Hash)
+
+		advance(chain, watcher, "0x12", "0xh12", "0xh11")
+
+		if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: tx.Hash}) {
+			tt.Fatalf("transaction should not be confirmed with a single confirmation")
+		}
+
+		advance(chain, watcher, "0x13", "0xh13", "0xh12")
+		notifier.waitFor(tt, EventMined, alice, tx.Hash)
+
+		if mined := notifier.tx(tx.Hash); mined.Receipt == nil || !mined.Receipt.Succeeded() {
+			tt.Fatalf("confirmed transaction should carry its receipt, got %+v", mined.Receipt)
+		}
+
+		watcher.trackMu.Lock()
+		defer watcher.trackMu.Unlock()
+
+		if len(watcher.tracked) != 0 {
+			tt.Fatalf("confirmed transactions should no longer be tracked")
+		}
+	})
+
+	t.Run("it tracks transactions that are already mined", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		tx := ethereum.Transaction{Hash: txHash("b"), From: alice, To: ptr(bob)}
+		advance(chain, watcher, "0x11", "0xh11", "0xh10", tx)
+		advance(chain, watcher, "0x12", "0xh12", "0xh11")
+		advance(chain, watcher, "0x13", "0xh13", "0xh12")
+
+		if err := watcher.TrackTx(tx.Hash); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		notifier.waitFor(tt, EventMined, alice, tx.Hash)
+
+		if notifier.has(recordedEvent{kind: EventPending, address: alice, hash: tx.Hash}) {
+			tt.Fatalf("mined transactions should not be notified as pending")
+		}
+	})
+
+	t.Run("it notifies transactions reverted by a reorganization", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		tx := ethereum.Transaction{Hash: txHash("c"), From: alice, To: ptr(bob)}
+		advance(chain, watcher, "0x11", "0xh11a", "0xh10", tx)
+
+		if err := watcher.TrackTx(tx.Hash); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		notifier.waitFor(tt, EventUnconfirmed, alice, tx.Hash)
+
+		// 0x11 is replaced by a sibling without the transaction.
+		chain.addBlock("0x11", "0xh11b", "0xh10")
+		advance(chain, watcher, "0x12", "0xh12b", "0xh11b")
+
+		notifier.waitFor(tt, EventReverted, alice, tx.Hash)
+
+		// the transaction is mined again further up the chain.
+		watcher.processNextBlock()
+		watcher.processNextBlock()
+		advance(chain, watcher, "0x13", "0xh13b", "0xh12b", tx)
+		advance(chain, watcher, "0x14", "0xh14b", "0xh13b")
+		advance(chain, watcher, "0x15", "0xh15b", "0xh14b")
+
+		notifier.waitFor(tt, EventMined, alice, tx.Hash)
+
+		if mined := notifier.tx(tx.Hash); mined.BlockNumber == nil || *mined.BlockNumber != "0x13" {
+			tt.Fatalf("got block %v, want 0x13", mined.BlockNumber)
+		}
+	})
+
+	t.Run("it notifies transactions replaced by another with the same nonce", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		original := ethereum.Transaction{Hash: txHash("d"), From: alice, To: ptr(bob), Nonce: "0x7"}
+		chain.addPending(original)
+
+		if err := watcher.TrackTx(original.Hash); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		notifier.waitFor(tt, EventPending, alice, original.Hash)
+
+		speedUp := ethereum.Transaction{Hash: txHash("e"), From: alice, To: ptr(bob), Nonce: "0x7"}
+		chain.evict(original.Hash)
+		advance(chain, watcher, "0x11", "0xh11", "0xh10", speedUp)
+
+		notifier.waitFor(tt, EventReplaced, alice, original.Hash)
+
+		replaced := notifier.tx(original.Hash)
+		if replaced.ReplacedBy == nil || *replaced.ReplacedBy != speedUp.Hash {
+			tt.Fatalf("got ReplacedBy %v, want %s", replaced.ReplacedBy, speedUp.Hash)
+		}
+	})
+
+	t.Run("it notifies transactions the node no longer knows", func(tt *testing.T) {
+		chain, notifier, watcher := setup(tt)
+
+		tx := ethereum.Transaction{Hash: txHash("f"), From: alice, To: ptr(bob), Nonce: "0x1"}
+		chain.addPending(tx)
+
+		if err := watcher.TrackTx(tx.Hash); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		notifier.waitFor(tt, EventPending, alice, tx.Hash)
+
+		chain.evict(tx.Hash)
+		now := time.Now()
+
+		watcher.checkTracked(now.Add(30 * time.Second))
+
+		if notifier.has(recordedEvent{kind: EventDropped, address: alice, hash: tx.Hash}) {
+			tt.Fatalf("transaction should not be dropped before DropAfter has elapsed")
+		}
+
+		watcher.checkTracked(now.Add(2 * time.Minute))
+		notifier.waitFor(tt, EventDropped, alice, tx.Hash)
+	})
+
+	t.Run("it notifies transactions that are never found with no address", func(tt *testing.T) {
+		_, notifier, watcher := setup(tt)
+
+		hash := txHash("9")
+		if err := watcher.TrackTx(hash); err != nil {
+			tt.Fatalf("could not track: %v", err)
+		}
+
+		watcher.checkTracked(time.Now().Add(2 * time.Minute))
+		notifier.waitFor(tt, EventDropped, "", hash)
+	})
+
+	t.Run("it does not hold up TrackTx while looking up other transactions", func(tt *testing.T) {
+		_, notifier, watcher := setup(tt)
+
+		chain := &stallingChain{
+			mockChain: watcher.rpcClient.(*mockChain),
+			stalled:   txHash("5104"),
+			stalling:  make(chan struct{}),
+			release:   make(chan struct{}),
+		}
+		watcher.rpcClient = chain
+
+		defer close(chain.release)
+
+		go watcher.TrackTx(txHash("5104"))
+
+		chain.waitStalled(tt)
+
+		tx := ethereum.Transaction{Hash: txHash("b"), From: alice, To: ptr(bob), Nonce: "0x2"}
+		chain.addPending(tx)
+
+		done := make(chan error, 1)
+		go func() { done <- watcher.TrackTx(tx.Hash) }()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				tt.Fatalf("could not track: %v", err)
+			}
+
+		case <-time.After(time.Second):
+			tt.Fatalf("TrackTx was held up by the lookup of another transaction")
+		}
+
+		notifier.waitFor(tt, EventPending, alice, tx.Hash)
+	})
+}
+
+// stallingChain is a mockChain whose lookups of one transaction hang until released.
+type stallingChain struct {
+	*mockChain
+
+	stalled  string
+	stalling chan struct{}
+	release  chan struct{}
+	once     sync.Once
+}
+
+func (s *stallingChain) GetTransactionByHash(hash string) (*rpc.Response[ethereum.Transaction], error) {
+	if hash == s.stalled {
+		s.once.Do(func() { close(s.stalling) })
+		<-s.release
+	}
+
+	return s.mockChain.GetTransactionByHash(hash)
+}
+
+func (s *stallingChain) waitStalled(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-s.stalling:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the lookup to stall")
+	}
+}
+
+// txHash returns a 32-byte transaction hash ending in the given hex digits.
+func txHash(suffix string) string {
+	return "0x" + strings.Repeat("0", 64-len(suffix)) + suffix
+}
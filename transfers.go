@@ -0,0 +1,100 @@
+package txnotify
+
+import (
+	"fmt"
+
+	"github.com/aalbacetef/txnotify/ethereum"
+	"github.com/aalbacetef/txnotify/rpc"
+)
+
+// attachTransfers decodes the token (ERC-20) and NFT (ERC-721/ERC-1155) transfers emitted in
+// block. The receipts' logs are used when every transaction has one, otherwise the transfer logs
+// are fetched with eth_getLogs.
+func (watcher *Watcher) attachTransfers(blockNum string, block *ethereum.Block) error {
+	if len(block.Transactions) == 0 {
+		return nil
+	}
+
+	logs, complete := receiptLogs(block.Transactions)
+
+	if !complete {
+		transferTopics := []string{
+			ethereum.TransferEventTopic,
+			ethereum.TransferSingleEventTopic,
+			ethereum.TransferBatchEventTopic,
+		}
+
+		filter := rpc.LogFilter{Topics: []any{transferTopics}}
+
+		if block.Hash != "" {
+			filter.BlockHash = block.Hash
+		} else {
+			filter.FromBlock = blockNum
+			filter.ToBlock = blockNum
+		}
+
+		resp, err := watcher.rpcClient.GetLogs(filter)
+		if err != nil {
+			return fmt.Errorf("could not get logs: %w", err)
+		}
+
+		logs = resp.Result
+	}
+
+	block.TokenTransfers = nil
+	block.NFTTransfers = nil
+
+	for _, log := range logs {
+		if transfer, ok := ethereum.DecodeTokenTransfer(log); ok {
+			block.TokenTransfers = append(block.TokenTransfers, transfer)
+			continue
+		}
+
+		if transfers, ok := ethereum.DecodeNFTTransfers(log); ok {
+			block.NFTTransfers = append(block.NFTTransfers, transfers...)
+		}
+	}
+
+	return nil
+}
+
+// receiptLogs collects the logs of every transaction, reporting whether all of them had a receipt.
+func receiptLogs(txList []ethereum.Transaction) ([]ethereum.Log, bool) {
+	var logs []ethereum.Log
+
+	for _, tx := range txList {
+		if tx.Receipt == nil {
+			return nil, false
+		}
+
+		logs = append(logs, tx.Receipt.Logs...)
+	}
+
+	return logs, true
+}
+
+func tokenTransferParties(transfer ethereum.TokenTransfer) (string, string) {
+	return transfer.From, transfer.To
+}
+
+func nftTransferParties(transfer ethereum.NFTTransfer) (string, string) {
+	return transfer.From, transfer.To
+}
+
+// groupByParties indexes transfers by their (normalized) sender and recipient addresses.
+func groupByParties[T any](transfers []T, parties func(T) (string, string)) map[string][]T {
+	byAddress := make(map[string][]T)
+
+	for _, transfer := range transfers {
+		from, to := parties(transfer)
+		from, to = NormalizeAddress(from), NormalizeAddress(to)
+
+		byAddress[from] = append(byAddress[from], transfer)
+
+		if to != from {
+			byAddress[to] = append(byAddress[to], transfer)
+		}
+	}
+
+	return byAddress
+}
@@ -2,9 +2,13 @@ package txnotify
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,19 +16,100 @@ import (
 	"github.com/aalbacetef/txnotify/rpc"
 )
 
+var (
+	ErrNotSubscribed = errors.New("address is not subscribed")
+
+	// ErrBlockNotFound is returned for blocks the node does not have yet, which it reports
+	// with a null result rather than an error.
+	ErrBlockNotFound = errors.New("block not found")
+)
+
 type RPCClient interface {
 	GetBlockByNumber(blockNum string) (*rpc.Response[ethereum.Block], error)
 	GetCurrentBlockNumber() (*rpc.Response[string], error)
+	GetBlockReceipts(blockNum string) (*rpc.Response[[]ethereum.Receipt], error)
+	GetTransactionReceipt(hash string) (*rpc.Response[ethereum.Receipt], error)
+	GetLogs(filter rpc.LogFilter) (*rpc.Response[[]ethereum.Log], error)
+	GetTransactionByHash(hash string) (*rpc.Response[ethereum.Transaction], error)
 }
 
+// Notifier receives the events of a Watcher. Notify is called concurrently from Config.Workers
+// goroutines, so implementations must be safe for concurrent use, but the events of an address
+// are passed one at a time and in order. Events it returns an error for are retried, see
+// Config.MaxAttempts.
 type Notifier interface {
-	Notify(address string, txList []ethereum.Transaction)
+	Notify(event Event) error
+}
+
+// AnyAddress registers a destination of the notifiers routing events by address (such as the
+// webhook and email ones) for the events of every address. It suits TrackTx, whose events are
+// addressed to the transaction's sender, which is not known upfront.
+const AnyAddress = "*"
+
+// ChainIDClient is an optional interface an RPCClient can implement to report the chain id
+// set on every Event.
+type ChainIDClient interface {
+	ChainID() (*rpc.Response[string], error)
 }
 
 type Config struct {
 	PollInterval time.Duration
-	BatchSize    int
-	BatchDelay   time.Duration
+
+	// BatchSize is the maximum number of blocks processed in one burst when the watcher
+	// is behind the chain head.
+	BatchSize int
+
+	// BatchDelay is the pause between bursts while catching up.
+	BatchDelay time.Duration
+
+	// StartBlock is the first block processed by Listen, as a block number (hex or decimal)
+	// or one of the "earliest", "latest", "safe" or "finalized" tags. Defaults to the latest block.
+	StartBlock string
+
+	// Checkpoints persists the last block whose events were all delivered or dead-lettered.
+	// If set, NewWatcher resumes from the stored checkpoint, taking precedence over StartBlock.
+	Checkpoints CheckpointStore
+
+	// Confirmations is the number of blocks that must be built on top of a block before
+	// its transactions are passed to Notifier.Notify. Zero notifies on the tip block.
+	Confirmations int
+
+	// NotifyUnconfirmed sends an EventUnconfirmed notification as soon as a block is processed,
+	// ahead of the Notify call once it is confirmed. Only used when Confirmations is set.
+	NotifyUnconfirmed bool
+
+	// WatchPending sends an EventPending notification when a transaction involving a subscribed
+	// address enters the node's mempool. Listen subscribes to newPendingTransactions on websocket
+	// endpoints and polls a pending transaction filter otherwise.
+	WatchPending bool
+
+	// DropAfter is how long a pending transaction must be missing from the node before it is
+	// notified as EventDropped. Only used when WatchPending is set.
+	DropAfter time.Duration
+
+	// MaxAttempts is how many times an event is passed to the Notifier before it is stored
+	// in DeadLetters.
+	MaxAttempts int
+
+	// RetryBackoff is the pause before retrying a failed event, doubled on every retry
+	// up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// DeadLetters stores the events that failed every attempt, so they can be inspected and
+	// replayed. If nil, they are logged and discarded.
+	DeadLetters DeadLetterStore
+
+	// Workers is the number of goroutines delivering events. The events of an address are
+	// always delivered by the same worker, in order.
+	Workers int
+
+	// QueueSize is the number of events that can wait for delivery, shared between the workers.
+	QueueSize int
+
+	// DropPolicy decides what happens to new events when a worker's queue is full. Dropped events
+	// are stored in DeadLetters. Defaults to DropNone.
+	DropPolicy DropPolicy
 }
 
 // NewWatcher initializes a new Watcher instance with a JSON-RPC client, logger, in-memory cache, and notifier.
@@ -41,12 +126,80 @@ func NewWatcher(rpcEndpoint string, cfg Config, notifier Notifier) (*Watcher, er
 		pollInterval = defaultPollInterval
 	}
 
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	batchDelay := cfg.BatchDelay
+	if batchDelay == 0 {
+		batchDelay = defaultBatchDelay
+	}
+
+	dropAfter := cfg.DropAfter
+	if dropAfter == 0 {
+		dropAfter = defaultDropAfter
+	}
+
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	retryBackoff := cfg.RetryBackoff
+	if retryBackoff == 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+
+	maxRetryBackoff := cfg.MaxRetryBackoff
+	if maxRetryBackoff == 0 {
+		maxRetryBackoff = defaultMaxBackoff
+	}
+
+	workers := cfg.Workers
+	if workers == 0 {
+		workers = defaultWorkers
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+
+	dropPolicy := cfg.DropPolicy
+	if dropPolicy == "" {
+		dropPolicy = DropNone
+	}
+
+	if !slices.Contains([]DropPolicy{DropNone, DropNewest, DropOldest}, dropPolicy) {
+		return nil, fmt.Errorf("invalid drop policy '%s'", dropPolicy)
+	}
+
 	watcher := &Watcher{
-		pollInterval: pollInterval,
-		rpcClient:    client,
-		logger:       slog.New(slog.NewJSONHandler(os.Stdout, nil)),
-		cache:        NewInMemoryCache(),
-		notifier:     notifier,
+		pollInterval:      pollInterval,
+		startBlock:        cfg.StartBlock,
+		batchSize:         batchSize,
+		batchDelay:        batchDelay,
+		confirmations:     cfg.Confirmations,
+		notifyUnconfirmed: cfg.NotifyUnconfirmed,
+		watchPending:      cfg.WatchPending,
+		dropAfter:         dropAfter,
+		maxAttempts:       maxAttempts,
+		retryBackoff:      retryBackoff,
+		maxRetryBackoff:   maxRetryBackoff,
+		deadLetters:       cfg.DeadLetters,
+		workers:           workers,
+		queueSize:         queueSize,
+		dropPolicy:        dropPolicy,
+		rpcClient:         client,
+		logger:            slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		cache:             NewInMemoryCache(),
+		notifier:          notifier,
+		checkpoints:       cfg.Checkpoints,
+	}
+
+	if err := watcher.resumeFromCheckpoint(); err != nil {
+		return nil, err
 	}
 
 	return watcher, nil
@@ -54,58 +207,202 @@ func NewWatcher(rpcEndpoint string, cfg Config, notifier Notifier) (*Watcher, er
 
 const (
 	defaultPollInterval = 15 * time.Second
+	defaultBatchSize    = 10
+	defaultBatchDelay   = time.Second
+	defaultDropAfter    = 5 * time.Minute
+	defaultMaxAttempts  = 5
+	defaultRetryBackoff = time.Second
+	defaultMaxBackoff   = time.Minute
+	defaultWorkers      = 4
+	defaultQueueSize    = 1024
 )
 
 type Watcher struct {
-	mu            sync.Mutex
-	subscriptions []string
-	cancel        context.CancelFunc
-	pollInterval  time.Duration
-	rpcClient     RPCClient
-	cache         Cache
-	currentBlock  string
-	latestBlock   string
-	logger        *slog.Logger
-	notifier      Notifier
+	mu                   sync.Mutex
+	subscriptions        map[string]subscriptionRef
+	cancel               context.CancelFunc
+	pollInterval         time.Duration
+	startBlock           string
+	batchSize            int
+	batchDelay           time.Duration
+	confirmations        int
+	notifyUnconfirmed    bool
+	pendingConfirmations []pendingBlock
+	watchPending         bool
+	mempool              map[string]mempoolTx
+	dropAfter            time.Duration
+	maxAttempts          int
+	retryBackoff         time.Duration
+	maxRetryBackoff      time.Duration
+	deadLetters          DeadLetterStore
+	workers              int
+	queueSize            int
+	dropPolicy           DropPolicy
+	dispatcher           *dispatcher
+	stopped              chan struct{}
+	chainID              string
+	trackMu              sync.Mutex
+	tracked              map[string]*trackedTx
+	rpcClient            RPCClient
+	cache                Cache
+	blockMu              sync.Mutex
+	currentBlock         string
+	liveStart            string
+	latestBlock          string
+	logger               *slog.Logger
+	notifier             Notifier
+	checkpoints          CheckpointStore
+	checkpoint           int
+	deliveryMu           sync.Mutex
+	deliveries           []*blockDelivery
 }
 
+// Close stops the watcher, waiting for the events already queued to be delivered. Events
+// waiting to be retried are dead-lettered rather than waiting out their backoff.
 func (watcher *Watcher) Close() error {
 	watcher.mu.Lock()
-	defer watcher.mu.Unlock()
 
 	if watcher.cancel != nil {
 		watcher.cancel()
 		watcher.cancel = nil
 	}
 
+	dispatcher := watcher.dispatcher
+	watcher.mu.Unlock()
+
+	watcher.stop()
+
+	if dispatcher != nil {
+		dispatcher.close()
+	}
+
+	if closer, ok := watcher.rpcClient.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("could not close rpc client: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// Subscribe registers a new address for monitoring by normalizing and adding it to the subscription list.
-func (watcher *Watcher) Subscribe(address string) error {
+// Subscribe registers a subscription for monitoring. Subscriptions are reference counted: subscribing
+// again with the same address and filters keeps a single subscription, which lasts until every
+// Subscribe call has been matched by an Unsubscribe. An address with several subscriptions is
+// notified of the transactions matching any of them.
+func (watcher *Watcher) Subscribe(sub Subscription) error {
+	_, err := watcher.addSubscription(sub, false)
+
+	return err
+}
+
+// addSubscription takes a reference to sub, registering it if needed. If onlyNew is set, an
+// existing subscription is left untouched. It reports whether the subscription was registered.
+func (watcher *Watcher) addSubscription(sub Subscription, onlyNew bool) (bool, error) {
+	compiled, err := sub.Compiled()
+	if err != nil {
+		return false, err
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	if watcher.subscriptions == nil {
+		watcher.subscriptions = make(map[string]subscriptionRef)
+	}
+
+	key := sub.Key()
+
+	ref, ok := watcher.subscriptions[key]
+	if ok && onlyNew {
+		return false, nil
+	}
+
+	if !ok {
+		ref.sub = compiled
+	}
+
+	ref.refs++
+	watcher.subscriptions[key] = ref
+
+	return !ok, nil
+}
+
+// Unsubscribe releases one reference to a subscription, and removes it once no references are left.
+// It returns ErrNotSubscribed if no subscription with the same address and filters exists.
+func (watcher *Watcher) Unsubscribe(sub Subscription) error {
 	watcher.mu.Lock()
 	defer watcher.mu.Unlock()
 
-	watcher.subscriptions = append(watcher.subscriptions, normalizeAddress(address))
+	key := sub.Key()
+
+	ref, ok := watcher.subscriptions[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, NormalizeAddress(sub.Address))
+	}
+
+	if ref.refs <= 1 {
+		delete(watcher.subscriptions, key)
+		return nil
+	}
+
+	ref.refs--
+	watcher.subscriptions[key] = ref
 
 	return nil
 }
 
-// Listen starts the polling loop to watch for new Ethereum blocks and process transactions in real-time.
+// Subscriptions returns the active subscriptions, in normalized form, sorted by address.
+func (watcher *Watcher) Subscriptions() []Subscription {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(watcher.subscriptions))
+	for _, key := range slices.Sorted(maps.Keys(watcher.subscriptions)) {
+		subs = append(subs, watcher.subscriptions[key].sub)
+	}
+
+	return subs
+}
+
+// Listen watches for new Ethereum blocks and processes their transactions in real-time.
+// If the RPC client supports newHeads subscriptions (websocket endpoints) new blocks are pushed
+// to the watcher, otherwise the latest block number is polled every PollInterval.
+// With WatchPending set, the mempool is watched alongside.
 func (watcher *Watcher) Listen(backgroundCtx context.Context) error {
+	if err := watcher.initStartBlock(); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(backgroundCtx)
 
 	watcher.cancel = cancel
 
+	context.AfterFunc(ctx, watcher.stop)
+
+	watcher.loadChainID()
+
+	if watcher.watchPending {
+		go watcher.listenPending(ctx)
+		go watcher.sweepMempool(ctx)
+	}
+
+	if subscriber, ok := watcher.rpcClient.(HeadSubscriber); ok {
+		return watcher.listenHeads(ctx, subscriber)
+	}
+
+	return watcher.poll(ctx)
+}
+
+// poll checks for a new block every pollInterval.
+func (watcher *Watcher) poll(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Println("done")
 			return nil
 
 		case <-time.After(watcher.pollInterval):
 			watcher.checkNewBlock()
-			watcher.processNextBlock()
+			watcher.catchUp(ctx)
 		}
 	}
 }
@@ -121,12 +418,15 @@ func (watcher *Watcher) checkNewBlock() {
 		return
 	}
 
-	blockNumber := resp.Result
+	watcher.setLatestBlock(resp.Result)
+}
 
+// setLatestBlock records the chain head and releases notifications that are now confirmed.
+func (watcher *Watcher) setLatestBlock(blockNumber string) {
 	watcher.mu.Lock()
-	defer watcher.mu.Unlock()
 
 	if watcher.latestBlock == blockNumber {
+		watcher.mu.Unlock()
 		watcher.logger.Debug("no new block, skipping...")
 		return
 	}
@@ -136,19 +436,26 @@ func (watcher *Watcher) checkNewBlock() {
 		"block number", blockNumber,
 	)
 	watcher.latestBlock = blockNumber
+	watcher.mu.Unlock()
+
+	watcher.releaseConfirmed()
+	watcher.checkTracked(time.Now())
 }
 
 // processNextBlock determines the next block to process, fetches its transactions, updates cache,
-// and triggers notifications.
-// @TODO: handle case where block still has pending tx
-// assumes: block number increases incrementally and linearly.
-func (watcher *Watcher) processNextBlock() { //nolint:funlen
+// and triggers notifications. It reports whether the watcher made progress.
+// If the block does not build on the cached predecessor, the orphaned blocks are rolled back instead.
+func (watcher *Watcher) processNextBlock() bool { //nolint:funlen
+	// processing a block is serialized with Backfill, see backfillBlock.
+	watcher.blockMu.Lock()
+	defer watcher.blockMu.Unlock()
+
 	state := watcher.copyState()
 
 	// @TODO: update in case new subs came in
-	if state.currentBlock == state.latestBlock {
+	if state.latestBlock == "" || (state.currentBlock == state.latestBlock && watcher.isProcessed(state.currentBlock)) {
 		watcher.logger.Debug("no new block to process, skipping")
-		return
+		return false
 	}
 
 	offset := 1
@@ -164,16 +471,37 @@ func (watcher *Watcher) processNextBlock() { //nolint:funlen
 	currentBlockNum, err := strToHex(state.currentBlock)
 	if err != nil {
 		watcher.logger.Error("could not parse current block number", "currentBlockNum", currentBlockNum)
-		return
+		return false
 	}
 
-	nextBlockNum := state.currentBlock
+	latestBlockNum, err := strToHex(state.latestBlock)
+	if err != nil {
+		watcher.logger.Error("could not parse latest block number", "latestBlock", state.latestBlock)
+		return false
+	}
+
+	nextNum := currentBlockNum
 
 	processed, err := watcher.cache.GetBlockProcessed(state.currentBlock)
 	if err == nil && processed {
-		nextBlockNum = numToStr(currentBlockNum + offset)
+		nextNum = currentBlockNum + offset
+	}
+
+	// the head can move back, e.g. behind a load balancer with a lagging node, or the start
+	// block can be ahead of it: wait for the chain rather than skipping blocks it does not have.
+	if nextNum > latestBlockNum {
+		watcher.logger.Debug("next block is ahead of the chain head, waiting", "nextBlockNum", nextNum)
+		return false
 	}
 
+	nextBlockNum := numToStr(nextNum)
+
+	watcher.mu.Lock()
+	if watcher.liveStart == "" {
+		watcher.liveStart = nextBlockNum
+	}
+	watcher.mu.Unlock()
+
 	watcher.logger.Info(
 		"processing next block",
 		"latestBlock", state.latestBlock,
@@ -181,10 +509,10 @@ func (watcher *Watcher) processNextBlock() { //nolint:funlen
 		"currentBlockNum", state.currentBlock,
 	)
 
-	block, err := watcher.fetchBlockInfoIfNotExist(nextBlockNum)
+	block, err := watcher.fetchBlockInfoIfNotExist(nextBlockNum, state.subs)
 	if err != nil {
 		watcher.logger.Error("fetchBlockInfo failed", "blockNum", nextBlockNum, "error", err)
-		return
+		return false
 	}
 
 	count := len(block.Transactions)
@@ -194,13 +522,30 @@ func (watcher *Watcher) processNextBlock() { //nolint:funlen
 		"txCount", count,
 	)
 
+	if watcher.detectReorg(nextBlockNum, block) {
+		watcher.logger.Warn(
+			"chain reorganization detected",
+			"blockNum", nextBlockNum,
+			"parentHash", block.ParentHash,
+		)
+
+		if err := watcher.rollback(nextBlockNum, block, state.subs); err != nil {
+			watcher.logger.Error("rollback failed", "blockNum", nextBlockNum, "error", err)
+			return false
+		}
+
+		watcher.checkTracked(time.Now())
+
+		return true
+	}
+
 	if err := watcher.cache.SetBlockProcessed(nextBlockNum); err != nil {
 		watcher.logger.Error(
 			"could not set block as processed, will be reprocessed",
 			"blockNum", nextBlockNum,
 			"error", err,
 		)
-		return
+		return false
 	}
 
 	watcher.logger.Info(
@@ -208,11 +553,40 @@ func (watcher *Watcher) processNextBlock() { //nolint:funlen
 		"blockNum", nextBlockNum,
 	)
 
-	go watcher.notifyForBlock(nextBlockNum, state.subs)
+	watcher.settleMempool(block.Transactions)
+	watcher.settleTracked(nextBlockNum, block)
+	watcher.dispatchBlock(nextBlockNum, state.subs, false)
 
 	watcher.mu.Lock()
 	watcher.currentBlock = nextBlockNum
 	watcher.mu.Unlock()
+
+	return true
+}
+
+// catchUp processes every block between currentBlock and latestBlock, in bursts of at most
+// batchSize blocks separated by batchDelay.
+func (watcher *Watcher) catchUp(ctx context.Context) {
+	for {
+		for processed := 0; watcher.batchSize <= 0 || processed < watcher.batchSize; processed++ {
+			if !watcher.processNextBlock() {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watcher.batchDelay):
+		}
+	}
+}
+
+// isProcessed reports whether blockNum has been marked as processed in the cache.
+func (watcher *Watcher) isProcessed(blockNum string) bool {
+	processed, err := watcher.cache.GetBlockProcessed(blockNum)
+
+	return err == nil && processed
 }
 
 type State struct {
@@ -226,53 +600,110 @@ func (watcher *Watcher) copyState() State {
 	watcher.mu.Lock()
 	defer watcher.mu.Unlock()
 
-	subs := make([]string, len(watcher.subscriptions))
-	copy(subs, watcher.subscriptions)
+	addresses := make(map[string]struct{}, len(watcher.subscriptions))
+	for _, ref := range watcher.subscriptions {
+		addresses[ref.sub.Address] = struct{}{}
+	}
 
 	return State{
-		subs:         subs,
+		subs:         slices.Sorted(maps.Keys(addresses)),
 		currentBlock: watcher.currentBlock,
 		latestBlock:  watcher.latestBlock,
 	}
 }
 
-// fetchBlockInfoIfNotExistchecks if a block is already cached, and fetches it from the blockchain if not.
-func (watcher *Watcher) fetchBlockInfoIfNotExist(blockNum string) (ethereum.Block, error) {
+// fetchBlockInfoIfNotExist checks if a block is already cached, and fetches it from the blockchain
+// (along with its receipts and token transfers) if not.
+func (watcher *Watcher) fetchBlockInfoIfNotExist(blockNum string, subs []string) (ethereum.Block, error) {
 	if block, err := watcher.cache.GetBlock(blockNum); err == nil {
 		return block, nil
 	}
 
-	blockInfoResp, err := watcher.rpcClient.GetBlockByNumber(blockNum)
+	block, err := watcher.getBlockByNumber(blockNum)
 	if err != nil {
 		return ethereum.Block{}, fmt.Errorf("could not get block info: %w", err)
 	}
 
-	if err := watcher.cache.AddBlock(blockNum, blockInfoResp.Result); err != nil {
+	if err := watcher.enrichBlock(blockNum, &block, subs); err != nil {
+		return ethereum.Block{}, err
+	}
+
+	if err := watcher.cache.AddBlock(blockNum, block); err != nil {
 		return ethereum.Block{}, fmt.Errorf("could not store block info to cache: %w", err)
 	}
 
-	return blockInfoResp.Result, nil
+	return block, nil
 }
 
-// notifyForBlock filters transactions involving subscribed addresses and invokes the notifier for each address.
-func (watcher *Watcher) notifyForBlock(blockNum string, subs []string) {
-	txxMap := make(map[string][]ethereum.Transaction, len(subs))
+// getBlockByNumber fetches a block, returning ErrBlockNotFound if the node answers with a null block.
+func (watcher *Watcher) getBlockByNumber(blockNum string) (ethereum.Block, error) {
+	resp, err := watcher.rpcClient.GetBlockByNumber(blockNum)
+	if err != nil {
+		return ethereum.Block{}, err
+	}
 
+	if resp.Result.Hash == "" {
+		return ethereum.Block{}, fmt.Errorf("%w: %s", ErrBlockNotFound, blockNum)
+	}
+
+	return resp.Result, nil
+}
+
+// notifyForBlock filters transactions involving subscribed addresses and invokes the notifier for each address.
+// If delivery is set, the events are counted against it, see beginDelivery.
+func (watcher *Watcher) notifyForBlock(kind EventKind, blockNum string, subs []string, delivery *blockDelivery) {
 	block, err := watcher.cache.GetBlock(blockNum)
 	if err != nil {
 		watcher.logger.Error("cache.GetBlock failed", "blockNum", blockNum, "error", err)
 		return
 	}
 
-	for _, tx := range block.Transactions {
-		from := normalizeAddress(tx.From)
+	watcher.notifyContents(kind, block, subs, delivery)
+}
+
+// notifyContents sends each subscribed address one event with the transactions and transfers
+// of block involving it, as selected by its subscriptions' filters.
+func (watcher *Watcher) notifyContents(kind EventKind, block ethereum.Block, subs []string, delivery *blockDelivery) {
+	txxMap := groupByAddress(block.Transactions)
+	tokenTransfers := groupByParties(block.TokenTransfers, tokenTransferParties)
+	nftTransfers := groupByParties(block.NFTTransfers, nftTransferParties)
+
+	template := watcher.blockEvent(kind, "", block)
+	template.delivery = delivery
+
+	for _, addr := range subs {
+		event := template
+		event.Address = addr
+		event.Transactions = txxMap[addr]
+		event.TokenTransfers = tokenTransfers[addr]
+		event.NFTTransfers = nftTransfers[addr]
+
+		// addresses without subscriptions, such as those passed to Backfill, are not filtered.
+		if addrSubs := watcher.subscriptionsFor(addr); len(addrSubs) > 0 {
+			event = filterContents(event, addrSubs)
+		}
+
+		watcher.notify(event)
+	}
+
+	if kind == EventMined {
+		watcher.notifyDeployments(block, subs, delivery)
+	}
+}
+
+// groupByAddress indexes transactions by their (normalized) sender and receiver addresses.
+func groupByAddress(txList []ethereum.Transaction) map[string][]ethereum.Transaction {
+	txxMap := make(map[string][]ethereum.Transaction)
+
+	for _, tx := range txList {
+		from := NormalizeAddress(tx.From)
 		txxMap[from] = append(txxMap[from], tx)
 
 		if tx.To == nil {
 			continue
 		}
 
-		to := normalizeAddress(*tx.To)
+		to := NormalizeAddress(*tx.To)
 
 		if to == from {
 			continue
@@ -281,7 +712,5 @@ func (watcher *Watcher) notifyForBlock(blockNum string, subs []string) {
 		txxMap[to] = append(txxMap[to], tx)
 	}
 
-	for _, addr := range subs {
-		go watcher.notifier.Notify(addr, txxMap[addr])
-	}
+	return txxMap
 }
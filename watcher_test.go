@@ -2,10 +2,15 @@ package txnotify
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -77,14 +82,754 @@ func TestWatcher(t *testing.T) { //nolint:gocognit
 	})
 }
 
+func TestWatcherReorg(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+	)
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.addBlock("0x11", "0xh11a", "0xh10", ethereum.Transaction{Hash: "0xtxa", From: alice})
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	if err := watcher.Subscribe(Subscription{Address: bob}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	chain.setHead("0x10")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	chain.setHead("0x11")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventMined, alice, "0xtxa")
+
+	// 0x11 is replaced by a sibling, and the chain grows on top of it.
+	chain.addBlock("0x11", "0xh11b", "0xh10", ethereum.Transaction{Hash: "0xtxb", From: bob})
+	chain.addBlock("0x12", "0xh12b", "0xh11b")
+	chain.setHead("0x12")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventReverted, alice, "0xtxa")
+
+	if state := watcher.copyState(); state.currentBlock != "0x11" {
+		t.Fatalf("watcher should rewind to 0x11, got %s", state.currentBlock)
+	}
+
+	block, err := watcher.cache.GetBlock("0x11")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if block.Hash != "0xh11b" {
+		t.Fatalf("orphaned block was not replaced: got %s, want 0xh11b", block.Hash)
+	}
+
+	if _, err := watcher.cache.GetTx("0xtxa"); err == nil {
+		t.Fatalf("orphaned transaction should be evicted from the cache")
+	}
+
+	watcher.processNextBlock()
+	notifier.waitFor(t, EventMined, bob, "0xtxb")
+
+	watcher.processNextBlock()
+
+	if state := watcher.copyState(); state.currentBlock != "0x12" {
+		t.Fatalf("watcher should have caught up to 0x12, got %s", state.currentBlock)
+	}
+}
+
+func TestWatcherConfirmations(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9", ethereum.Transaction{Hash: "0xtxa", From: alice})
+	chain.addBlock("0x11", "0xh11", "0xh10")
+	chain.addBlock("0x12", "0xh12", "0xh11")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.confirmations = 2
+	watcher.notifyUnconfirmed = true
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	chain.setHead("0x10")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	notifier.waitFor(t, EventUnconfirmed, alice, "0xtxa")
+
+	chain.setHead("0x11")
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: "0xtxa"}) {
+		t.Fatalf("transaction should not be notified with a single confirmation")
+	}
+
+	chain.setHead("0x12")
+	watcher.checkNewBlock()
+
+	notifier.waitFor(t, EventMined, alice, "0xtxa")
+}
+
+func TestWatcherCatchUp(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+
+	for n := 0x11; n <= 0x16; n++ {
+		tx := ethereum.Transaction{Hash: fmt.Sprintf("0xtx%x", n), From: alice}
+		chain.addBlock(numToStr(n), fmt.Sprintf("0xh%x", n), fmt.Sprintf("0xh%x", n-1), tx)
+	}
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.batchSize = 2
+	watcher.batchDelay = time.Millisecond
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	chain.setHead("0x10")
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	chain.setHead("0x16")
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	if state := watcher.copyState(); state.currentBlock != "0x16" {
+		t.Fatalf("watcher should have caught up to 0x16, got %s", state.currentBlock)
+	}
+
+	for n := 0x11; n <= 0x16; n++ {
+		notifier.waitFor(t, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+	}
+}
+
+func TestWatcherHeadBehind(t *testing.T) {
+	const alice = "0xa11ce"
+
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.addBlock("0x11", "0xh11", "0xh10")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+	watcher.batchDelay = time.Millisecond
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	chain.setHead("0x11")
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	// a lagging node reports an older head, and returns null for the blocks it does not have.
+	chain.setHead("0x10")
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	if state := watcher.copyState(); state.currentBlock != "0x11" {
+		t.Fatalf("watcher should wait at 0x11, got %s", state.currentBlock)
+	}
+
+	// the head is ahead of the blocks the node can serve.
+	chain.setHead("0x12")
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	if state := watcher.copyState(); state.currentBlock != "0x11" {
+		t.Fatalf("watcher should not skip the missing block 0x12, got %s", state.currentBlock)
+	}
+
+	if watcher.isProcessed("0x12") {
+		t.Fatalf("missing block 0x12 should not be marked as processed")
+	}
+
+	chain.addBlock("0x12", "0xh12", "0xh11", ethereum.Transaction{Hash: "0xtx12", From: alice})
+	watcher.catchUp(context.Background())
+
+	if state := watcher.copyState(); state.currentBlock != "0x12" {
+		t.Fatalf("watcher should have processed 0x12, got %s", state.currentBlock)
+	}
+
+	notifier.waitFor(t, EventMined, alice, "0xtx12")
+}
+
+func TestWatcherStartBlockAheadOfHead(t *testing.T) {
+	chain := newMockChain()
+	chain.addBlock("0x10", "0xh10", "0xh9")
+	chain.setHead("0x10")
+
+	watcher := mustMakeWatcher(t, chain)
+	watcher.startBlock = "0x20"
+
+	if err := watcher.initStartBlock(); err != nil {
+		t.Fatalf("could not init start block: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.catchUp(context.Background())
+
+	if state := watcher.copyState(); state.currentBlock != "0x20" {
+		t.Fatalf("watcher should wait for the start block, got %s", state.currentBlock)
+	}
+
+	if watcher.isProcessed("0x20") {
+		t.Fatalf("start block should not be processed before the chain reaches it")
+	}
+}
+
+func TestWatcherSubscriptions(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+	)
+
+	t.Run("it de-duplicates and reference counts subscriptions", func(tt *testing.T) {
+		watcher := mustMakeWatcher(tt, newMockChain())
+
+		for _, addr := range []string{bob, alice, "0x0A11CE"} {
+			if err := watcher.Subscribe(Subscription{Address: addr}); err != nil {
+				tt.Fatalf("could not subscribe: %v", err)
+			}
+		}
+
+		if got, want := subscribedAddresses(watcher), []string{alice, bob}; !slices.Equal(got, want) {
+			tt.Fatalf("got %v, want %v", got, want)
+		}
+
+		if err := watcher.Unsubscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not unsubscribe: %v", err)
+		}
+
+		if got, want := subscribedAddresses(watcher), []string{alice, bob}; !slices.Equal(got, want) {
+			tt.Fatalf("alice is still referenced once, got %v, want %v", got, want)
+		}
+
+		if err := watcher.Unsubscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not unsubscribe: %v", err)
+		}
+
+		if got, want := subscribedAddresses(watcher), []string{bob}; !slices.Equal(got, want) {
+			tt.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("it fails to unsubscribe unknown addresses", func(tt *testing.T) {
+		watcher := mustMakeWatcher(tt, newMockChain())
+
+		if err := watcher.Unsubscribe(Subscription{Address: alice}); !errors.Is(err, ErrNotSubscribed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrNotSubscribed)
+		}
+	})
+
+	t.Run("it stops notifying unsubscribed addresses", func(tt *testing.T) {
+		chain := newMockChain()
+		chain.addBlock("0x10", "0xh10", "0xh9")
+		chain.setHead("0x10")
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+		for _, addr := range []string{alice, bob} {
+			if err := watcher.Subscribe(Subscription{Address: addr}); err != nil {
+				tt.Fatalf("could not subscribe: %v", err)
+			}
+		}
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		if err := watcher.Unsubscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not unsubscribe: %v", err)
+		}
+
+		chain.addBlock("0x11", "0xh11", "0xh10",
+			ethereum.Transaction{Hash: "0xtxa", From: alice},
+			ethereum.Transaction{Hash: "0xtxb", From: bob},
+		)
+		chain.setHead("0x11")
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		notifier.waitFor(tt, EventMined, bob, "0xtxb")
+
+		if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: "0xtxa"}) {
+			tt.Fatalf("unsubscribed address should not be notified")
+		}
+	})
+}
+
+func TestWatcherBackfill(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+	)
+
+	newChain := func() *mockChain {
+		chain := newMockChain()
+
+		for n := 0x10; n <= 0x14; n++ {
+			tx := ethereum.Transaction{Hash: fmt.Sprintf("0xtx%x", n), From: alice}
+			chain.addBlock(numToStr(n), fmt.Sprintf("0xh%x", n), fmt.Sprintf("0xh%x", n-1), tx)
+		}
+
+		chain.setHead("0x14")
+
+		return chain
+	}
+
+	chain := newChain()
+
+	t.Run("it notifies a historical range", func(tt *testing.T) {
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.batchSize = 2
+		watcher.batchDelay = time.Millisecond
+
+		if err := watcher.Subscribe(Subscription{Address: bob}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		if err := watcher.Backfill(context.Background(), "16", "latest", alice); err != nil {
+			tt.Fatalf("backfill failed: %v", err)
+		}
+
+		for n := 0x10; n <= 0x14; n++ {
+			notifier.waitFor(tt, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+		}
+
+		if processed, err := watcher.cache.GetBlockProcessed("0x12"); err != nil || !processed {
+			tt.Fatalf("backfilled block should be marked as processed")
+		}
+	})
+
+	t.Run("it notifies blocks without enough confirmations once they have them", func(tt *testing.T) {
+		chain := newChain()
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.confirmations = 2
+
+		if err := watcher.Backfill(context.Background(), "16", "latest", alice); err != nil {
+			tt.Fatalf("backfill failed: %v", err)
+		}
+
+		for n := 0x10; n <= 0x12; n++ {
+			notifier.waitFor(tt, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+		}
+
+		for n := 0x13; n <= 0x14; n++ {
+			if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: fmt.Sprintf("0xtx%x", n)}) {
+				tt.Fatalf("block 0x%x has fewer than 2 confirmations and should not be notified yet", n)
+			}
+		}
+
+		chain.addBlock("0x15", "0xh15", "0xh14")
+		chain.addBlock("0x16", "0xh16", "0xh15")
+		chain.setHead("0x16")
+		watcher.checkNewBlock()
+
+		for n := 0x13; n <= 0x14; n++ {
+			notifier.waitFor(tt, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+		}
+	})
+
+	t.Run("it leaves the blocks of a running watcher to it", func(tt *testing.T) {
+		chain := newChain()
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.pollInterval = 5 * time.Millisecond
+		watcher.confirmations = 2
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			if err := watcher.Listen(ctx); err != nil {
+				tt.Errorf("listen failed: %v", err)
+			}
+		}()
+
+		defer watcher.Close()
+
+		for deadline := time.Now().Add(time.Second); !watcher.isProcessed("0x14"); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				tt.Fatalf("the watcher did not process the latest block")
+			}
+		}
+
+		if err := watcher.Backfill(ctx, "16", "latest"); err != nil {
+			tt.Fatalf("backfill failed: %v", err)
+		}
+
+		chain.addBlock("0x15", "0xh15", "0xh14")
+		chain.addBlock("0x16", "0xh16", "0xh15")
+		chain.setHead("0x16")
+
+		for n := 0x10; n <= 0x14; n++ {
+			notifier.waitFor(tt, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+		}
+
+		seen := make(map[string]int)
+
+		for _, event := range notifier.all() {
+			for _, tx := range event.Transactions {
+				if event.Kind == EventMined {
+					seen[tx.Hash]++
+				}
+			}
+		}
+
+		for hash, count := range seen {
+			if count != 1 {
+				tt.Fatalf("%s was notified %d times, want once", hash, count)
+			}
+		}
+	})
+
+	t.Run("it starts from the configured start block", func(tt *testing.T) {
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+		watcher.startBlock = "0x12"
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		if err := watcher.initStartBlock(); err != nil {
+			tt.Fatalf("could not init start block: %v", err)
+		}
+
+		watcher.checkNewBlock()
+		watcher.catchUp(context.Background())
+
+		for n := 0x12; n <= 0x14; n++ {
+			notifier.waitFor(tt, EventMined, alice, fmt.Sprintf("0xtx%x", n))
+		}
+
+		if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: "0xtx11"}) {
+			tt.Fatalf("blocks before the start block should not be processed")
+		}
+	})
+}
+
+func TestWatcherReceipts(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		bob   = "0xb0b"
+	)
+
+	newChain := func() *mockChain {
+		chain := newMockChain()
+		chain.addBlock(
+			"0x10", "0xh10", "0xh9",
+			ethereum.Transaction{Hash: "0xtxa", From: alice},
+			ethereum.Transaction{Hash: "0xtxb", From: bob},
+		)
+		chain.setReceipt(ethereum.Receipt{TransactionHash: "0xtxa", Status: ethereum.ReceiptStatusFailure})
+		chain.setHead("0x10")
+
+		return chain
+	}
+
+	t.Run("it attaches receipts to notified transactions", func(tt *testing.T) {
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, newChain(), notifier)
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		notifier.waitFor(tt, EventMined, alice, "0xtxa")
+
+		receipt := notifier.tx("0xtxa").Receipt
+		if receipt == nil {
+			tt.Fatalf("notified transaction has no receipt")
+		}
+
+		if receipt.Succeeded() {
+			tt.Fatalf("reverted transaction should not be reported as successful")
+		}
+	})
+
+	t.Run("it falls back to per-transaction receipts", func(tt *testing.T) {
+		chain := newChain()
+		chain.noBlockReceipts = true
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		watcher.checkNewBlock()
+		watcher.processNextBlock()
+
+		notifier.waitFor(tt, EventMined, alice, "0xtxa")
+
+		if receipt := notifier.tx("0xtxa").Receipt; receipt == nil || receipt.Succeeded() {
+			tt.Fatalf("expected a failed receipt, got %v", receipt)
+		}
+
+		chain.mu.Lock()
+		defer chain.mu.Unlock()
+
+		if chain.receiptLookupCount != 1 {
+			tt.Fatalf("only subscribed transactions should be looked up, got %d lookups", chain.receiptLookupCount)
+		}
+	})
+
+	t.Run("it falls back to receipts for subscribed addresses when backfilling others", func(tt *testing.T) {
+		chain := newChain()
+		chain.noBlockReceipts = true
+
+		notifier := newRecordingNotifier()
+		watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+		if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+			tt.Fatalf("could not subscribe: %v", err)
+		}
+
+		if err := watcher.Backfill(context.Background(), "0x10", "0x10", bob); err != nil {
+			tt.Fatalf("backfill failed: %v", err)
+		}
+
+		block, err := watcher.cache.GetBlock("0x10")
+		if err != nil {
+			tt.Fatalf("could not get cached block: %v", err)
+		}
+
+		for _, tx := range block.Transactions {
+			if tx.Receipt == nil {
+				tt.Fatalf("cached transaction %s has no receipt", tx.Hash)
+			}
+		}
+	})
+}
+
+func TestWatcherTokenTransfers(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		carol = "0xca201"
+		usdt  = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	)
+
+	newChain := func() *mockChain {
+		chain := newMockChain()
+		chain.addBlock("0x10", "0xh10", "0xh9", ethereum.Transaction{Hash: "0xtxa", From: carol, To: ptr(usdt)})
+		chain.setReceipt(ethereum.Receipt{
+			TransactionHash: "0xtxa",
+			Status:          ethereum.ReceiptStatusSuccess,
+			Logs: []ethereum.Log{{
+				Address: usdt,
+				Topics: []string{
+					ethereum.TransferEventTopic,
+					"0x00000000000000000000000000000000000000000000000000000000000ca201",
+					"0x00000000000000000000000000000000000000000000000000000000000a11ce",
+				},
+				Data:            "0x00000000000000000000000000000000000000000000000000000000000f4240",
+				TransactionHash: "0xtxa",
+			}},
+		})
+		chain.setHead("0x10")
+
+		return chain
+	}
+
+	for _, noBlockReceipts := range []bool{false, true} {
+		t.Run(fmt.Sprintf("it notifies the recipient (noBlockReceipts=%v)", noBlockReceipts), func(tt *testing.T) {
+			chain := newChain()
+			chain.noBlockReceipts = noBlockReceipts
+
+			notifier := newRecordingNotifier()
+			watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+			if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+				tt.Fatalf("could not subscribe: %v", err)
+			}
+
+			watcher.checkNewBlock()
+			watcher.processNextBlock()
+
+			transfer := notifier.waitForTransfer(tt, EventMined, alice, "0xtxa")
+
+			if transfer.Token != usdt {
+				tt.Fatalf("(token) got %s, want %s", transfer.Token, usdt)
+			}
+
+			if transfer.From != "0x"+strings.Repeat("0", 35)+"ca201" {
+				tt.Fatalf("(from) got %s", transfer.From)
+			}
+
+			if transfer.Amount != "0xf4240" {
+				tt.Fatalf("(amount) got %s, want 0xf4240", transfer.Amount)
+			}
+		})
+	}
+
+	for _, sub := range []Subscription{
+		{Address: alice, MinValue: "0x2"},
+		{Address: alice, Expression: "value >= 2"},
+	} {
+		t.Run(fmt.Sprintf("it skips transfers for subscriptions with transaction filters (%+v)", sub), func(tt *testing.T) {
+			chain := newChain()
+
+			notifier := newRecordingNotifier()
+			watcher := mustMakeWatcherWithNotifier(tt, chain, notifier)
+
+			if err := watcher.Subscribe(sub); err != nil {
+				tt.Fatalf("could not subscribe: %v", err)
+			}
+
+			watcher.checkNewBlock()
+			watcher.processNextBlock()
+
+			// a matching transaction of alice's, delivered after anything sent for the first block.
+			chain.addBlock("0x11", "0xh11", "0xh10", ethereum.Transaction{Hash: "0xtxb", From: carol, To: ptr(alice), Value: "0x2"})
+			chain.setHead("0x11")
+			watcher.checkNewBlock()
+			watcher.processNextBlock()
+
+			notifier.waitFor(tt, EventMined, alice, "0xtxb")
+
+			notifier.mu.Lock()
+			defer notifier.mu.Unlock()
+
+			if len(notifier.transfers) != 0 {
+				tt.Fatalf("got %d transfers, want none", len(notifier.transfers))
+			}
+		})
+	}
+}
+
+func TestWatcherNFTTransfers(t *testing.T) {
+	const (
+		alice = "0xa11ce"
+		carol = "0xca201"
+		punks = "0xb47e3cd837ddf8e4c57f05d70ab865de6e193bbb"
+		items = "0x76be3b62873462d2142405439777e971754e8e77"
+	)
+
+	word := func(v string) string {
+		return "0x" + strings.Repeat("0", 64-len(v)) + v
+	}
+
+	chain := newMockChain()
+	chain.addBlock(
+		"0x10", "0xh10", "0xh9",
+		ethereum.Transaction{Hash: "0xtx721", From: carol, To: ptr(punks)},
+		ethereum.Transaction{Hash: "0xtx1155", From: carol, To: ptr(items)},
+	)
+	chain.setReceipt(ethereum.Receipt{
+		TransactionHash: "0xtx721",
+		Status:          ethereum.ReceiptStatusSuccess,
+		Logs: []ethereum.Log{{
+			Address:         punks,
+			Topics:          []string{ethereum.TransferEventTopic, word("ca201"), word("a11ce"), word("2a")},
+			Data:            "0x",
+			TransactionHash: "0xtx721",
+		}},
+	})
+	chain.setReceipt(ethereum.Receipt{
+		TransactionHash: "0xtx1155",
+		Status:          ethereum.ReceiptStatusSuccess,
+		Logs: []ethereum.Log{{
+			Address: items,
+			Topics:  []string{ethereum.TransferBatchEventTopic, word("ca201"), word("ca201"), word("a11ce")},
+			// ids [0x1, 0x2] and values [0x5, 0x6].
+			Data: word("40") + word("a0")[2:] +
+				word("2")[2:] + word("1")[2:] + word("2")[2:] +
+				word("2")[2:] + word("5")[2:] + word("6")[2:],
+			TransactionHash: "0xtx1155",
+		}},
+	})
+	chain.setHead("0x10")
+
+	notifier := newRecordingNotifier()
+	watcher := mustMakeWatcherWithNotifier(t, chain, notifier)
+
+	if err := watcher.Subscribe(Subscription{Address: alice}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	watcher.checkNewBlock()
+	watcher.processNextBlock()
+
+	erc721 := notifier.waitForNFTTransfers(t, EventMined, alice, "0xtx721")
+	if len(erc721) != 1 || erc721[0].Standard != ethereum.ERC721 || erc721[0].TokenID != "0x2a" {
+		t.Fatalf("unexpected ERC-721 transfers: %+v", erc721)
+	}
+
+	erc1155 := notifier.waitForNFTTransfers(t, EventMined, alice, "0xtx1155")
+	if len(erc1155) != 2 {
+		t.Fatalf("expected 2 ERC-1155 transfers, got %+v", erc1155)
+	}
+
+	for i, want := range []struct{ id, amount string }{{"0x1", "0x5"}, {"0x2", "0x6"}} {
+		got := erc1155[i]
+		if got.Standard != ethereum.ERC1155 || got.TokenID != want.id || got.Amount != want.amount {
+			t.Fatalf("(erc1155[%d]) got %+v, want id=%s amount=%s", i, got, want.id, want.amount)
+		}
+	}
+
+	if notifier.has(recordedEvent{kind: EventMined, address: alice, hash: "0xtx721"}) {
+		t.Fatalf("NFT transfers should not be reported as plain transactions to the recipient")
+	}
+}
+
+func subscribedAddresses(watcher *Watcher) []string {
+	var addresses []string
+
+	for _, sub := range watcher.Subscriptions() {
+		addresses = append(addresses, sub.Address)
+	}
+
+	return addresses
+}
+
 func mustMakeWatcher(t *testing.T, mock RPCClient) *Watcher {
 	t.Helper()
 
+	return mustMakeWatcherWithNotifier(t, mock, mockNotifier{})
+}
+
+func mustMakeWatcherWithNotifier(t *testing.T, mock RPCClient, notifier Notifier) *Watcher {
+	t.Helper()
+
 	watcher := &Watcher{
 		pollInterval: 5 * time.Second,
 		rpcClient:    mock,
 		logger:       slog.New(slog.NewTextHandler(nopWriter{}, nil)),
-		notifier:     mockNotifier{},
+		notifier:     notifier,
 		cache:        NewInMemoryCache(),
 	}
 
@@ -93,10 +838,12 @@ func mustMakeWatcher(t *testing.T, mock RPCClient) *Watcher {
 
 type mockNotifier struct{}
 
-func (mockNotifier) Notify(address string, txList []ethereum.Transaction) {
-	for _, tx := range txList {
-		fmt.Printf("%s) got tx: %s\n", address, tx.Hash)
+func (mockNotifier) Notify(event Event) error {
+	for _, tx := range event.Transactions {
+		fmt.Printf("%s) got %s tx: %s\n", event.Address, event.Kind, tx.Hash)
 	}
+
+	return nil
 }
 
 type mockRPCClient struct {
@@ -132,6 +879,43 @@ func (m *mockRPCClient) GetBlockByNumber(blockNum string) (*rpc.Response[ethereu
 	return m.blockInfo, nil
 }
 
+func (m *mockRPCClient) GetBlockReceipts(blockNum string) (*rpc.Response[[]ethereum.Receipt], error) {
+	if blockNum != m.blockNum {
+		return nil, fmt.Errorf("mock expects block number %s, got %s", m.blockNum, blockNum)
+	}
+
+	receipts := make([]ethereum.Receipt, 0, len(m.blockInfo.Result.Transactions))
+	for _, tx := range m.blockInfo.Result.Transactions {
+		receipts = append(receipts, successReceipt(tx))
+	}
+
+	return &rpc.Response[[]ethereum.Receipt]{JSONRPC: "2.0", Result: receipts}, nil
+}
+
+func (m *mockRPCClient) GetTransactionReceipt(hash string) (*rpc.Response[ethereum.Receipt], error) {
+	for _, tx := range m.blockInfo.Result.Transactions {
+		if tx.Hash == hash {
+			return &rpc.Response[ethereum.Receipt]{JSONRPC: "2.0", Result: successReceipt(tx)}, nil
+		}
+	}
+
+	return &rpc.Response[ethereum.Receipt]{JSONRPC: "2.0"}, nil
+}
+
+func (m *mockRPCClient) GetTransactionByHash(hash string) (*rpc.Response[ethereum.Transaction], error) {
+	for _, tx := range m.blockInfo.Result.Transactions {
+		if tx.Hash == hash {
+			return &rpc.Response[ethereum.Transaction]{JSONRPC: "2.0", Result: tx}, nil
+		}
+	}
+
+	return &rpc.Response[ethereum.Transaction]{JSONRPC: "2.0"}, nil
+}
+
+func (m *mockRPCClient) GetLogs(_ rpc.LogFilter) (*rpc.Response[[]ethereum.Log], error) {
+	return &rpc.Response[[]ethereum.Log]{JSONRPC: "2.0"}, nil
+}
+
 func (m *mockRPCClient) GetCurrentBlockNumber() (*rpc.Response[string], error) {
 	return &rpc.Response[string]{
 		ID:      1,
@@ -139,3 +923,379 @@ func (m *mockRPCClient) GetCurrentBlockNumber() (*rpc.Response[string], error) {
 		Result:  m.blockNum,
 	}, nil
 }
+
+// mockChain is an RPCClient serving an in-memory chain that tests can rewrite to simulate reorgs.
+// Transactions succeed unless a receipt is set for them.
+type mockChain struct {
+	mu                 sync.Mutex
+	head               string
+	blocks             map[string]ethereum.Block
+	receipts           map[string]ethereum.Receipt
+	noBlockReceipts    bool
+	receiptLookupCount int
+
+	// mempool holds pending transactions, and filterChanges the hashes added since the
+	// pending transaction filter was last polled.
+	mempool       map[string]ethereum.Transaction
+	filterChanges []string
+}
+
+func newMockChain() *mockChain {
+	return &mockChain{
+		blocks:   make(map[string]ethereum.Block),
+		receipts: make(map[string]ethereum.Receipt),
+		mempool:  make(map[string]ethereum.Transaction),
+	}
+}
+
+func (m *mockChain) addPending(tx ethereum.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mempool[tx.Hash] = tx
+	m.filterChanges = append(m.filterChanges, tx.Hash)
+}
+
+// evict removes a transaction from the mempool, as nodes do when it is dropped.
+func (m *mockChain) evict(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.mempool, hash)
+}
+
+func (m *mockChain) NewPendingTransactionFilter() (*rpc.Response[string], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.filterChanges = nil
+
+	return &rpc.Response[string]{JSONRPC: "2.0", Result: "0xfilter"}, nil
+}
+
+func (m *mockChain) GetFilterChanges(filterID string) (*rpc.Response[[]string], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if filterID != "0xfilter" {
+		return nil, rpc.JSONRPCError{Code: -32000, Message: "filter not found"}
+	}
+
+	changes := m.filterChanges
+	m.filterChanges = nil
+
+	return &rpc.Response[[]string]{JSONRPC: "2.0", Result: changes}, nil
+}
+
+// GetTransactionByHash returns mined transactions with their block number and hash set, and pending ones without.
+func (m *mockChain) GetTransactionByHash(hash string) (*rpc.Response[ethereum.Transaction], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for num, block := range m.blocks {
+		for _, tx := range block.Transactions {
+			if tx.Hash == hash {
+				tx.BlockNumber = ptr(num)
+				tx.BlockHash = ptr(block.Hash)
+				return &rpc.Response[ethereum.Transaction]{JSONRPC: "2.0", Result: tx}, nil
+			}
+		}
+	}
+
+	return &rpc.Response[ethereum.Transaction]{JSONRPC: "2.0", Result: m.mempool[hash]}, nil
+}
+
+func (m *mockChain) setReceipt(receipt ethereum.Receipt) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.receipts[receipt.TransactionHash] = receipt
+}
+
+func (m *mockChain) receiptFor(tx ethereum.Transaction) ethereum.Receipt {
+	if receipt, ok := m.receipts[tx.Hash]; ok {
+		return receipt
+	}
+
+	return successReceipt(tx)
+}
+
+func (m *mockChain) GetBlockReceipts(blockNum string) (*rpc.Response[[]ethereum.Receipt], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.noBlockReceipts {
+		return nil, rpc.JSONRPCError{Code: -32601, Message: "the method eth_getBlockReceipts does not exist"}
+	}
+
+	block, ok := m.blocks[blockNum]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", blockNum)
+	}
+
+	receipts := make([]ethereum.Receipt, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		receipts = append(receipts, m.receiptFor(tx))
+	}
+
+	return &rpc.Response[[]ethereum.Receipt]{JSONRPC: "2.0", Result: receipts}, nil
+}
+
+func (m *mockChain) GetTransactionReceipt(hash string) (*rpc.Response[ethereum.Receipt], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.receiptLookupCount++
+
+	for _, block := range m.blocks {
+		for _, tx := range block.Transactions {
+			if tx.Hash == hash {
+				return &rpc.Response[ethereum.Receipt]{JSONRPC: "2.0", Result: m.receiptFor(tx)}, nil
+			}
+		}
+	}
+
+	return &rpc.Response[ethereum.Receipt]{JSONRPC: "2.0"}, nil
+}
+
+func (m *mockChain) addBlock(num, hash, parentHash string, txList ...ethereum.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocks[num] = ethereum.Block{
+		Hash:         hash,
+		Number:       num,
+		ParentHash:   parentHash,
+		Transactions: txList,
+	}
+}
+
+func (m *mockChain) setHead(num string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.head = num
+}
+
+func (m *mockChain) GetBlockByNumber(blockNum string) (*rpc.Response[ethereum.Block], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// like a node, unknown blocks are a null result rather than an error.
+	return &rpc.Response[ethereum.Block]{JSONRPC: "2.0", Result: m.blocks[blockNum]}, nil
+}
+
+func (m *mockChain) ChainID() (*rpc.Response[string], error) {
+	return &rpc.Response[string]{JSONRPC: "2.0", Result: "0x1"}, nil
+}
+
+func (m *mockChain) GetCurrentBlockNumber() (*rpc.Response[string], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &rpc.Response[string]{JSONRPC: "2.0", Result: m.head}, nil
+}
+
+// GetLogs supports filtering by block hash and by a list of alternatives for the first topic only.
+func (m *mockChain) GetLogs(filter rpc.LogFilter) (*rpc.Response[[]ethereum.Log], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var logs []ethereum.Log
+
+	for _, block := range m.blocks {
+		if block.Hash != filter.BlockHash {
+			continue
+		}
+
+		for _, tx := range block.Transactions {
+			for _, log := range m.receiptFor(tx).Logs {
+				if len(filter.Topics) > 0 && !slices.Contains(filter.Topics[0].([]string), log.Topics[0]) { //nolint:forcetypeassert
+					continue
+				}
+
+				logs = append(logs, log)
+			}
+		}
+	}
+
+	return &rpc.Response[[]ethereum.Log]{JSONRPC: "2.0", Result: logs}, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func successReceipt(tx ethereum.Transaction) ethereum.Receipt {
+	return ethereum.Receipt{
+		TransactionHash: tx.Hash,
+		From:            tx.From,
+		To:              tx.To,
+		Status:          ethereum.ReceiptStatusSuccess,
+	}
+}
+
+type recordedEvent struct {
+	kind    EventKind
+	address string
+	hash    string
+}
+
+// recordingNotifier stores every notified event and transaction so tests can wait on them.
+type recordingNotifier struct {
+	mu           sync.Mutex
+	received     []Event
+	events       []recordedEvent
+	txs          map[string]ethereum.Transaction
+	transfers    map[recordedEvent]ethereum.TokenTransfer
+	nftTransfers map[recordedEvent][]ethereum.NFTTransfer
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{
+		txs:          make(map[string]ethereum.Transaction),
+		transfers:    make(map[recordedEvent]ethereum.TokenTransfer),
+		nftTransfers: make(map[recordedEvent][]ethereum.NFTTransfer),
+	}
+}
+
+// waitForNFTTransfers waits for the NFT transfer notifications of a transaction and returns them.
+func (r *recordingNotifier) waitForNFTTransfers(t *testing.T, kind EventKind, address, hash string) []ethereum.NFTTransfer {
+	t.Helper()
+
+	const (
+		timeout = time.Second
+		step    = 5 * time.Millisecond
+	)
+
+	key := recordedEvent{kind: kind, address: address, hash: hash}
+
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(step) {
+		r.mu.Lock()
+		transfers, ok := r.nftTransfers[key]
+		r.mu.Unlock()
+
+		if ok {
+			return transfers
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s NFT transfers in %s to %s", kind, hash, address)
+
+	return nil
+}
+
+// waitForTransfer waits for a token transfer notification and returns it.
+func (r *recordingNotifier) waitForTransfer(t *testing.T, kind EventKind, address, hash string) ethereum.TokenTransfer {
+	t.Helper()
+
+	const (
+		timeout = time.Second
+		step    = 5 * time.Millisecond
+	)
+
+	key := recordedEvent{kind: kind, address: address, hash: hash}
+
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(step) {
+		r.mu.Lock()
+		transfer, ok := r.transfers[key]
+		r.mu.Unlock()
+
+		if ok {
+			return transfer
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s token transfer in %s to %s", kind, hash, address)
+
+	return ethereum.TokenTransfer{}
+}
+
+func (r *recordingNotifier) Notify(event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.received = append(r.received, event)
+
+	for _, tx := range event.Transactions {
+		r.events = append(r.events, recordedEvent{kind: event.Kind, address: event.Address, hash: tx.Hash})
+		r.txs[tx.Hash] = tx
+	}
+
+	for _, transfer := range event.TokenTransfers {
+		r.transfers[recordedEvent{kind: event.Kind, address: event.Address, hash: transfer.TransactionHash}] = transfer
+	}
+
+	for _, transfer := range event.NFTTransfers {
+		key := recordedEvent{kind: event.Kind, address: event.Address, hash: transfer.TransactionHash}
+		r.nftTransfers[key] = append(r.nftTransfers[key], transfer)
+	}
+
+	return nil
+}
+
+// event returns the first event of the given kind sent to address that carries the transaction hash.
+func (r *recordingNotifier) event(kind EventKind, address, hash string) (Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range r.received {
+		if event.Kind != kind || event.Address != address {
+			continue
+		}
+
+		if slices.ContainsFunc(event.Transactions, func(tx ethereum.Transaction) bool { return tx.Hash == hash }) {
+			return event, true
+		}
+	}
+
+	return Event{}, false
+}
+
+// all returns a copy of every received event.
+func (r *recordingNotifier) all() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.received)
+}
+
+// tx returns the last notified version of the transaction with the given hash.
+func (r *recordingNotifier) tx(hash string) ethereum.Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.txs[hash]
+}
+
+func (r *recordingNotifier) has(want recordedEvent) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ev := range r.events {
+		if ev == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *recordingNotifier) waitFor(t *testing.T, kind EventKind, address, hash string) {
+	t.Helper()
+
+	const (
+		timeout = time.Second
+		step    = 5 * time.Millisecond
+	)
+
+	want := recordedEvent{kind: kind, address: address, hash: hash}
+
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(step) {
+		if r.has(want) {
+			return
+		}
+	}
+
+	t.Fatalf("timed out waiting for %s notification of %s to %s", kind, hash, address)
+}
@@ -0,0 +1,373 @@
+// Package webhook implements a txnotify.Notifier that POSTs events as JSON to the webhook
+// URLs registered for their address, signed with HMAC-SHA256.
+//
+// Each endpoint is retried on its own, so an endpoint that fails does not cause the event to
+// be sent again to those that accepted it. Endpoints that never accept an event are reported
+// to Options.OnError rather than returned from Notify, which keeps the Watcher from retrying
+// (and multiplying) the attempts made here.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+)
+
+const (
+	// SignatureHeader holds "sha256=" followed by the hex-encoded HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the endpoint's secret.
+	SignatureHeader = "X-Txnotify-Signature"
+
+	// TimestampHeader holds the unix time (in seconds) the request was signed at.
+	TimestampHeader = "X-Txnotify-Timestamp"
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	ErrInvalidEndpoint  = errors.New("invalid webhook endpoint")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// StatusError is returned for requests answered with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("webhook %s answered with status %d", e.URL, e.StatusCode)
+}
+
+// retryable reports whether the receiver may accept the request later.
+func (e StatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// Endpoint is a webhook URL and the secret its requests are signed with.
+type Endpoint struct {
+	URL    string
+	Secret string
+}
+
+type Options struct {
+	// Timeout bounds each request, including reading the response.
+	Timeout time.Duration
+
+	// MaxAttempts is how many times a request answered with a 5xx or 429 status, or failing
+	// to get an answer, is sent before giving up.
+	MaxAttempts int
+
+	// Backoff is the pause before the first retry, doubled on every retry up to MaxBackoff.
+	// A Retry-After header (in seconds) takes precedence, also capped at MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Client sends the requests, defaults to a new http.Client.
+	Client *http.Client
+
+	// OnError is called with the events an endpoint did not accept after every attempt, for
+	// example to store them as dead letters. Defaults to logging them.
+	OnError func(event txnotify.Event, url string, err error)
+}
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 3
+	defaultBackoff     = 500 * time.Millisecond
+	defaultMaxBackoff  = 10 * time.Second
+)
+
+// Notifier sends every event to the endpoints registered for its address.
+type Notifier struct {
+	mu        sync.RWMutex
+	endpoints map[string][]Endpoint
+	closed    bool
+
+	// ctx is cancelled by Close, interrupting requests and the backoff between them.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	onError     func(txnotify.Event, string, error)
+}
+
+func NewNotifier(options Options) *Notifier {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	maxAttempts := options.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	backoff := options.Backoff
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+
+	maxBackoff := options.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	client := options.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	onError := options.OnError
+	if onError == nil {
+		onError = func(event txnotify.Event, url string, err error) {
+			slog.Default().Warn(
+				"webhook did not accept event",
+				"url", url,
+				"sequence", event.Sequence,
+				"address", event.Address,
+				"error", err,
+			)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Notifier{
+		endpoints:   make(map[string][]Endpoint),
+		ctx:         ctx,
+		cancel:      cancel,
+		onError:     onError,
+		client:      client,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+// AddEndpoint registers an endpoint for the events of address, or of every address with
+// txnotify.AnyAddress. Endpoints need an http(s) URL and a secret; registering the same URL
+// again replaces its secret.
+func (n *Notifier) AddEndpoint(address string, endpoint Endpoint) error {
+	parsed, err := url.Parse(endpoint.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: bad url '%s'", ErrInvalidEndpoint, endpoint.URL)
+	}
+
+	if endpoint.Secret == "" {
+		return fmt.Errorf("%w: missing secret for '%s'", ErrInvalidEndpoint, endpoint.URL)
+	}
+
+	address = routingKey(address)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	endpoints := slices.DeleteFunc(n.endpoints[address], func(e Endpoint) bool { return e.URL == endpoint.URL })
+	n.endpoints[address] = append(endpoints, endpoint)
+
+	return nil
+}
+
+// RemoveEndpoint unregisters the endpoint of address with the given URL.
+func (n *Notifier) RemoveEndpoint(address, rawURL string) {
+	address = routingKey(address)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	endpoints := slices.DeleteFunc(n.endpoints[address], func(e Endpoint) bool { return e.URL == rawURL })
+	if len(endpoints) == 0 {
+		delete(n.endpoints, address)
+		return
+	}
+
+	n.endpoints[address] = endpoints
+}
+
+// routingKey returns the key the endpoints of address are registered under.
+func routingKey(address string) string {
+	if address == txnotify.AnyAddress {
+		return address
+	}
+
+	return txnotify.NormalizeAddress(address)
+}
+
+// Notify posts the event to every endpoint of its address and of txnotify.AnyAddress, once per
+// URL. Endpoints that do not accept it
+// after every attempt are passed to Options.OnError; the error returned is only about the event
+// itself, such as it failing to encode, or the notifier being closed.
+func (n *Notifier) Notify(event txnotify.Event) error {
+	n.mu.RLock()
+	closed := n.closed
+	endpoints := slices.Clone(n.endpoints[event.Address])
+
+	for _, endpoint := range n.endpoints[txnotify.AnyAddress] {
+		if !slices.ContainsFunc(endpoints, func(e Endpoint) bool { return e.URL == endpoint.URL }) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	n.mu.RUnlock()
+
+	if closed {
+		return txnotify.ErrNotifierClosed
+	}
+
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if err := n.post(endpoint, body); err != nil {
+			n.onError(event, endpoint.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// Close interrupts the requests being made and the backoff between them, and rejects later events.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	n.cancel()
+}
+
+// post sends body to an endpoint, retrying network errors and 5xx/429 answers.
+func (n *Notifier) post(endpoint Endpoint, body []byte) error {
+	backoff := n.backoff
+
+	for attempt := 1; ; attempt++ {
+		wait, err := n.send(endpoint, body)
+		if err == nil {
+			return nil
+		}
+
+		var statusErr StatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return err
+		}
+
+		if attempt >= n.maxAttempts {
+			return fmt.Errorf("could not deliver to %s after %d attempts: %w", endpoint.URL, attempt, err)
+		}
+
+		if wait == 0 {
+			wait = backoff
+		}
+
+		timer := time.NewTimer(min(wait, n.maxBackoff))
+
+		select {
+		case <-timer.C:
+		case <-n.ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up delivering to %s after %d attempts: %w", endpoint.URL, attempt, n.ctx.Err())
+		}
+
+		backoff = min(2*backoff, n.maxBackoff)
+	}
+}
+
+// send makes a single signed request, returning the pause asked for by a Retry-After header.
+func (n *Notifier) send(endpoint Endpoint, body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, n.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("could not create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("could not post to %s: %w", endpoint.URL, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return 0, nil
+	}
+
+	return retryAfter(resp.Header), StatusError{URL: endpoint.URL, StatusCode: resp.StatusCode}
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// Sign returns the SignatureHeader value for a body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a received webhook request, and that it was signed within
+// tolerance of now, so recorded requests cannot be replayed later.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp := header.Get(TimestampHeader)
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp '%s'", ErrInvalidSignature, timestamp)
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrStaleTimestamp, age)
+	}
+
+	signature := header.Get(SignatureHeader)
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,384 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/txnotify"
+	"github.com/aalbacetef/txnotify/ethereum"
+)
+
+const (
+	alice  = "0xa11ce"
+	secret = "s3cr3t"
+)
+
+// receiver is a webhook endpoint answering with the queued statuses (200 once they run out)
+// and recording the events it accepted.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	delay    time.Duration
+	requests int
+	events   []txnotify.Event
+	errs     []error
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(r.delay)
+
+	body, err := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests++
+
+	if err == nil {
+		err = Verify(secret, req.Header, body, time.Minute, time.Now())
+	}
+
+	if err != nil {
+		r.errs = append(r.errs, err)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+
+		w.WriteHeader(status)
+
+		return
+	}
+
+	event := txnotify.Event{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.errs = append(r.errs, err)
+	}
+
+	r.events = append(r.events, event)
+}
+
+func (r *receiver) counts() (int, int, []error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.requests, len(r.events), r.errs
+}
+
+func testEvent() txnotify.Event {
+	return txnotify.Event{
+		Version:      txnotify.EventVersion,
+		Kind:         txnotify.EventMined,
+		Address:      alice,
+		BlockNumber:  "0x11",
+		Sequence:     7,
+		Transactions: []ethereum.Transaction{{Hash: "0xtxa", From: alice}},
+	}
+}
+
+// failures records the errors passed to Options.OnError.
+type failures struct {
+	mu   sync.Mutex
+	urls []string
+	errs []error
+}
+
+func (f *failures) record(_ txnotify.Event, url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.urls = append(f.urls, url)
+	f.errs = append(f.errs, err)
+}
+
+func (f *failures) all() ([]string, []error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.urls, f.errs
+}
+
+func setup(t *testing.T, recv *receiver) (*Notifier, *failures) {
+	t.Helper()
+
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	failed := &failures{}
+
+	notifier := NewNotifier(Options{
+		Timeout:    50 * time.Millisecond,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+		OnError:    failed.record,
+	})
+
+	if err := notifier.AddEndpoint("0xA11CE", Endpoint{URL: server.URL, Secret: secret}); err != nil {
+		t.Fatalf("could not add endpoint: %v", err)
+	}
+
+	return notifier, failed
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("it posts signed events", func(tt *testing.T) {
+		recv := &receiver{}
+		notifier, _ := setup(tt, recv)
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		requests, accepted, errs := recv.counts()
+		if requests != 1 || accepted != 1 || len(errs) != 0 {
+			tt.Fatalf("got %d requests, %d accepted (errors: %v), want 1 and 1", requests, accepted, errs)
+		}
+
+		if got := recv.events[0]; got.Sequence != 7 || got.Transactions[0].Hash != "0xtxa" {
+			tt.Fatalf("got %+v, want the notified event", got)
+		}
+	})
+
+	t.Run("it skips addresses without endpoints", func(tt *testing.T) {
+		recv := &receiver{}
+		notifier, _ := setup(tt, recv)
+
+		event := testEvent()
+		event.Address = "0xb0b"
+
+		if err := notifier.Notify(event); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if requests, _, _ := recv.counts(); requests != 0 {
+			tt.Fatalf("got %d requests, want none", requests)
+		}
+	})
+
+	t.Run("it posts the events of every address to AnyAddress endpoints once", func(tt *testing.T) {
+		recv := &receiver{}
+		server := httptest.NewServer(recv)
+		tt.Cleanup(server.Close)
+
+		notifier, _ := setup(tt, recv)
+
+		for _, address := range []string{alice, txnotify.AnyAddress} {
+			if err := notifier.AddEndpoint(address, Endpoint{URL: server.URL, Secret: secret}); err != nil {
+				tt.Fatalf("could not add endpoint: %v", err)
+			}
+		}
+
+		bob := testEvent()
+		bob.Address = "0xb0b"
+
+		// such as the drop of a tracked transaction that was never found.
+		unaddressed := testEvent()
+		unaddressed.Address = ""
+
+		for _, event := range []txnotify.Event{testEvent(), bob, unaddressed} {
+			if err := notifier.Notify(event); err != nil {
+				tt.Fatalf("could not notify: %v", err)
+			}
+		}
+
+		// alice's event goes to both servers, the others only to the catch-all one.
+		if requests, accepted, errs := recv.counts(); requests != 4 || accepted != 4 || len(errs) != 0 {
+			tt.Fatalf("got %d requests, %d accepted (errors: %v), want 4 and 4", requests, accepted, errs)
+		}
+	})
+
+	t.Run("it retries 5xx and 429 answers", func(tt *testing.T) {
+		recv := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+		notifier, _ := setup(tt, recv)
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("could not notify: %v", err)
+		}
+
+		if requests, accepted, _ := recv.counts(); requests != 3 || accepted != 1 {
+			tt.Fatalf("got %d requests, %d accepted, want 3 and 1", requests, accepted)
+		}
+	})
+
+	t.Run("it gives up after MaxAttempts", func(tt *testing.T) {
+		recv := &receiver{statuses: []int{500, 502, 503, 504}}
+		notifier, failed := setup(tt, recv)
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("endpoint failures should not be returned, got: '%v'", err)
+		}
+
+		var statusErr StatusError
+		if _, errs := failed.all(); len(errs) != 1 || !errors.As(errs[0], &statusErr) || statusErr.StatusCode != 503 {
+			tt.Fatalf("got: '%v', want a 503 StatusError", errs)
+		}
+
+		if requests, _, _ := recv.counts(); requests != defaultMaxAttempts {
+			tt.Fatalf("got %d requests, want %d", requests, defaultMaxAttempts)
+		}
+	})
+
+	t.Run("it does not retry other client errors", func(tt *testing.T) {
+		recv := &receiver{statuses: []int{http.StatusBadRequest}}
+		notifier, failed := setup(tt, recv)
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("endpoint failures should not be returned, got: '%v'", err)
+		}
+
+		var statusErr StatusError
+		if _, errs := failed.all(); len(errs) != 1 || !errors.As(errs[0], &statusErr) || statusErr.StatusCode != 400 {
+			tt.Fatalf("got: '%v', want a 400 StatusError", errs)
+		}
+
+		if requests, _, _ := recv.counts(); requests != 1 {
+			tt.Fatalf("got %d requests, want 1", requests)
+		}
+	})
+
+	t.Run("it times out slow receivers", func(tt *testing.T) {
+		recv := &receiver{delay: 200 * time.Millisecond}
+		notifier, failed := setup(tt, recv)
+		notifier.maxAttempts = 1
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("endpoint failures should not be returned, got: '%v'", err)
+		}
+
+		if _, errs := failed.all(); len(errs) != 1 {
+			tt.Fatalf("expected a timeout error, got %v", errs)
+		}
+	})
+
+	t.Run("it only reports the endpoints that failed", func(tt *testing.T) {
+		recv := &receiver{}
+		notifier, failed := setup(tt, recv)
+
+		broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		tt.Cleanup(broken.Close)
+
+		if err := notifier.AddEndpoint(alice, Endpoint{URL: broken.URL, Secret: secret}); err != nil {
+			tt.Fatalf("could not add endpoint: %v", err)
+		}
+
+		if err := notifier.Notify(testEvent()); err != nil {
+			tt.Fatalf("endpoint failures should not be returned, got: '%v'", err)
+		}
+
+		if urls, _ := failed.all(); len(urls) != 1 || urls[0] != broken.URL {
+			tt.Fatalf("got failures for %v, want only %s", urls, broken.URL)
+		}
+
+		if requests, accepted, _ := recv.counts(); requests != 1 || accepted != 1 {
+			tt.Fatalf("got %d requests, %d accepted, want the event delivered once", requests, accepted)
+		}
+	})
+
+	t.Run("it stops retrying when closed", func(tt *testing.T) {
+		recv := &receiver{statuses: []int{500, 500, 500}}
+		notifier, failed := setup(tt, recv)
+		notifier.backoff = time.Hour
+		notifier.maxBackoff = time.Hour
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			if err := notifier.Notify(testEvent()); err != nil {
+				tt.Errorf("endpoint failures should not be returned, got: '%v'", err)
+			}
+		}()
+
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if requests, _, _ := recv.counts(); requests > 0 {
+				break
+			}
+		}
+
+		notifier.Close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			tt.Fatalf("Close should interrupt the backoff")
+		}
+
+		if _, errs := failed.all(); len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+			tt.Fatalf("got %v, want the interrupted delivery reported", errs)
+		}
+
+		if err := notifier.Notify(testEvent()); !errors.Is(err, txnotify.ErrNotifierClosed) {
+			tt.Fatalf("got: '%v', want: '%v'", err, txnotify.ErrNotifierClosed)
+		}
+	})
+
+	t.Run("it rejects invalid endpoints", func(tt *testing.T) {
+		notifier := NewNotifier(Options{})
+
+		for _, endpoint := range []Endpoint{
+			{URL: "ftp://example.com", Secret: secret},
+			{URL: "not a url", Secret: secret},
+			{URL: "https://example.com/hook"},
+		} {
+			if err := notifier.AddEndpoint(alice, endpoint); !errors.Is(err, ErrInvalidEndpoint) {
+				tt.Fatalf("(%+v) got: '%v', want: '%v'", endpoint, err, ErrInvalidEndpoint)
+			}
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"kind":"mined"}`)
+	now := time.Unix(1700000000, 0)
+
+	sign := func(secret string, at time.Time) http.Header {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+
+		header := http.Header{}
+		header.Set(TimestampHeader, timestamp)
+		header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+		return header
+	}
+
+	t.Run("it accepts valid signatures", func(tt *testing.T) {
+		if err := Verify(secret, sign(secret, now), body, time.Minute, now.Add(30*time.Second)); err != nil {
+			tt.Fatalf("got: '%v', want no error", err)
+		}
+	})
+
+	t.Run("it rejects other secrets and bodies", func(tt *testing.T) {
+		if err := Verify(secret, sign("other", now), body, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrInvalidSignature)
+		}
+
+		if err := Verify(secret, sign(secret, now), []byte(`{}`), time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("it rejects replayed requests", func(tt *testing.T) {
+		if err := Verify(secret, sign(secret, now), body, time.Minute, now.Add(2*time.Minute)); !errors.Is(err, ErrStaleTimestamp) {
+			tt.Fatalf("got: '%v', want: '%v'", err, ErrStaleTimestamp)
+		}
+	})
+}